@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigKeyProfiles is the config file key of the named profiles, each overriding the
+// top-level settings when selected with `--profile`
+const ConfigKeyProfiles = "profiles"
+
+// redacted replaces secrets in `slacker config show`
+const redacted = "REDACTED"
+
+// secretSettings are redacted when showing the config
+var secretSettings = []string{SlackFlagToken, SlackFlagWebhookUrl}
+
+// alternativeSettings are groups of settings providing the same value, eg. the token. A
+// profile setting one of a group replaces whichever of the group is set at the top level
+var alternativeSettings = [][]string{tokenFlags}
+
+func init() {
+	ConfigShowCmd.Flags().StringP(SlackFlagOutput, "o", OutputYaml, "Output format: yaml or json")
+
+	ConfigCmd.AddCommand(ConfigShowCmd)
+}
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects the config used by the other commands",
+
+	Long: `Settings can be provided in a config file with '--config', in YAML or TOML. Each setting
+is named after a flag, eg. 'channel' or 'report-base-url', and applies to every command
+with that flag. Flags & env vars take precedence over the config file. The output format
+differs between commands, so can only be given with '--output'.
+
+Named profiles under 'profiles' override the top-level settings, and are selected with
+'--profile', eg. to keep the non-prod & prod channels in the same file.`,
+	Example: `# Show the settings used with the prod profile
+slacker config show --config slacker.yaml --profile prod`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var ConfigShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the effective config, merged from flags, env vars & the config file",
+
+	Args: cobra.NoArgs,
+	Long: `Prints the effective config of 'slacker slack-report', merged from flags, env vars, the
+config file & the selected profile. Secrets, such as the token, are redacted.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings := viper.AllSettings()
+		for _, key := range secretSettings {
+			if value, ok := settings[key].(string); ok && value != "" {
+				settings[key] = redacted
+			}
+		}
+
+		switch format, _ := cmd.Flags().GetString(SlackFlagOutput); format {
+		case OutputJson:
+			bytes, err := json.MarshalIndent(settings, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+			return err
+
+		case OutputYaml:
+			encoder := yaml.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent(2)
+			if err := encoder.Encode(settings); err != nil {
+				return err
+			}
+			return encoder.Close()
+
+		default:
+			return fmt.Errorf("unknown output format '%s'", format)
+		}
+	},
+}
+
+// loadConfig reads the settings of `configFile` into viper, with the settings of `profile`
+// taking precedence over the top-level settings. Without a config file, any settings
+// previously loaded are cleared
+func loadConfig(root *cobra.Command, configFile string, profile string) error {
+	settings, err := readConfig(root, configFile, profile)
+	if err != nil {
+		return err
+	}
+
+	// Viper can only replace its config by reading it, so the merged settings are re-encoded
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	viper.SetConfigType("json")
+	return viper.ReadConfig(bytes.NewReader(encoded))
+}
+
+// readConfig reads the settings of `configFile`, merged with those of `profile`. Every
+// setting must be named after a flag of one of the commands under `root`, so that typos
+// aren't silently ignored
+func readConfig(root *cobra.Command, configFile string, profile string) (map[string]any, error) {
+	if configFile == "" {
+		if profile != "" {
+			return nil, fmt.Errorf("Flag '--%s' cannot be used without '--%s'", RootFlagProfile, RootFlagConfig)
+		}
+		return map[string]any{}, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+
+	settings := v.AllSettings()
+	profiles, _ := settings[ConfigKeyProfiles].(map[string]any)
+	delete(settings, ConfigKeyProfiles)
+
+	known := settingNames(root)
+	errs := []error{checkSettings(known, "", settings)}
+	for _, name := range profileNames(profiles) {
+		if profileSettings, ok := profiles[name].(map[string]any); ok {
+			errs = append(errs, checkSettings(known, ConfigKeyProfiles+"."+name+".", profileSettings))
+		} else {
+			errs = append(errs, fmt.Errorf("invalid config file: profile '%s' is not a table of settings", name))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if profile != "" {
+		profileSettings, ok := profiles[profile].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unknown profile '%s', expected one of %s", profile, joinProfiles(profiles))
+		}
+		for _, group := range alternativeSettings {
+			if slices.ContainsFunc(group, func(key string) bool { _, ok := profileSettings[key]; return ok }) {
+				for _, key := range group {
+					delete(settings, key)
+				}
+			}
+		}
+		for key, value := range profileSettings {
+			settings[key] = value
+		}
+	}
+
+	return settings, nil
+}
+
+// checkSettings ensures that each of `settings` is one of the `known` setting names
+func checkSettings(known map[string]bool, prefix string, settings map[string]any) error {
+	var unknown []string
+	for key := range settings {
+		if !known[key] {
+			unknown = append(unknown, prefix+key)
+		}
+	}
+	sort.Strings(unknown)
+
+	var errs []error
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("invalid config file: unknown setting '%s'", key))
+	}
+
+	return errors.Join(errs...)
+}
+
+// settingNames are the names of the flags of `cmd` & its subcommands, which can also be set
+// in the config file. The config file flags themselves can't be, and neither can the output
+// format, as each command accepts different formats, eg. `text` is only valid for
+// `slacker history`
+func settingNames(cmd *cobra.Command) map[string]bool {
+	names := map[string]bool{}
+
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags()} {
+			flags.VisitAll(func(f *pflag.Flag) {
+				names[f.Name] = true
+			})
+		}
+		for _, child := range cmd.Commands() {
+			visit(child)
+		}
+	}
+	visit(cmd)
+
+	delete(names, "help")
+	delete(names, RootFlagConfig)
+	delete(names, RootFlagProfile)
+	delete(names, SlackFlagOutput)
+	return names
+}
+
+// profileNames are the names of the `profiles`, sorted
+func profileNames(profiles map[string]any) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinProfiles lists the profile names for error messages
+func joinProfiles(profiles map[string]any) string {
+	if len(profiles) == 0 {
+		return "none, as no profiles are defined"
+	}
+
+	names := profileNames(profiles)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("'%s'", name)
+	}
+	return strings.Join(names, ", ")
+}
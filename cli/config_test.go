@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+)
+
+const testConfig = `
+report-base-url: https://reports.example.com
+report-kind: nightly
+upload-threshold: 10
+token: xoxb-nonprod
+
+profiles:
+  nonprod:
+    channel: nonprod-alerts
+  prod:
+    channel: prod-alerts
+    report-kind: prod
+    token: xoxb-prod
+`
+
+// writeConfig writes a config file named `name`, returning its path
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// runConfigShow runs `slacker config show -o json`, returning the parsed settings
+func runConfigShow(t *testing.T, args ...string) (map[string]any, error) {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(ConfigShowCmd.Flags())
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{"config", "show", "-o", "json"}, args...))
+
+	settings := map[string]any{}
+	if err := RootCmd.Execute(); err != nil {
+		return settings, err
+	}
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &settings), stdout.String())
+	return settings, nil
+}
+
+func TestConfigProfileOverridesTopLevelSettings(t *testing.T) {
+	assert := assert.New(t)
+	config := writeConfig(t, "slacker.yaml", testConfig)
+
+	settings, err := runConfigShow(t, "--config", config, "--profile", "prod")
+	require.NoError(t, err)
+
+	assert.Equal("prod-alerts", settings[SlackFlagChannel])
+	assert.Equal("prod", settings[SlackFlagReportKind])
+	assert.Equal("https://reports.example.com", settings[SlackFlagReportBaseUrl])
+	assert.EqualValues(10, settings[SlackFlagUploadThreshold])
+	assert.Equal("REDACTED", settings[SlackFlagToken])
+	assert.Equal("", settings[SlackFlagWebhookUrl])
+
+	settings, err = runConfigShow(t, "--config", config)
+	require.NoError(t, err)
+
+	assert.Equal("", settings[SlackFlagChannel])
+	assert.Equal("nightly", settings[SlackFlagReportKind])
+}
+
+func TestConfigReadsToml(t *testing.T) {
+	config := writeConfig(t, "slacker.toml", `
+trend-days = 3
+
+[profiles.prod]
+channel = "prod-alerts"
+`)
+
+	settings, err := runConfigShow(t, "--config", config, "--profile", "prod")
+	require.NoError(t, err)
+
+	assert.Equal(t, "prod-alerts", settings[SlackFlagChannel])
+	assert.EqualValues(t, 3, settings[SlackFlagTrendDays])
+}
+
+func TestConfigIsOverriddenByFlags(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	config := writeConfig(t, "slacker.yaml", testConfig)
+
+	_, err := runSlackReport(t, fake, "--config", config, "--profile", "prod", "../examples/full.json")
+	require.NoError(t, err)
+
+	assert.Empty(fake.Messages("prod-alerts"))
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 1)
+	assert.Equal("prod", msgs[0].Metadata.EventPayload["kind"])
+
+	// Without a config, nothing is left over from the previous run
+	settings, err := runConfigShow(t)
+	require.NoError(t, err)
+	assert.Equal("", settings[SlackFlagReportKind])
+}
+
+func TestConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+	config := writeConfig(t, "slacker.yaml", testConfig)
+
+	_, err := runConfigShow(t, "--config", config, "--profile", "staging")
+	assert.ErrorContains(err, "unknown profile 'staging', expected one of 'nonprod', 'prod'")
+
+	_, err = runConfigShow(t, "--profile", "prod")
+	assert.ErrorContains(err, "Flag '--profile' cannot be used without '--config'")
+
+	_, err = runConfigShow(t, "--config", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(err, "could not read config file")
+
+	config = writeConfig(t, "slacker.yaml", "chanel: alerts\nprofiles:\n  prod:\n    tokn: xoxb-prod\n")
+	_, err = runConfigShow(t, "--config", config)
+	assert.ErrorContains(err, "unknown setting 'chanel'")
+	assert.ErrorContains(err, "unknown setting 'profiles.prod.tokn'")
+}
+
+func TestConfigReachesFetchAndHistory(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	dir := t.TempDir()
+
+	for _, date := range []string{"20-09-2023", "21-09-2023"} {
+		_, err := runSlackReport(t, fake, "--history-dir", dir, "--report-date", date, "../examples/full.json")
+		require.NoError(t, err)
+	}
+
+	config := writeConfig(t, "slacker.yaml", "date: 20-09-2023\nlookup-max-pages: 2\nhistory-dir: "+dir+"\ndays: 1\n")
+
+	stdout, err := runFetch(t, fake, "--config", config)
+	require.NoError(t, err)
+	assert.Contains(stdout, `"dev1"`)
+
+	assert.Equal(`DATE        ENVIRONMENTS  UNHEALTHY  ERRORS
+21-09-2023  3             2          22
+`, runHistory(t, "runs", "--config", config))
+
+	// Output formats differ between commands, so can only be given as flags
+	config = writeConfig(t, "slacker.yaml", "output: text\n")
+	_, err = runConfigShow(t, "--config", config)
+	assert.ErrorContains(err, "unknown setting 'output'")
+}
+
+func TestConfigTokenSourcePrecedence(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	fake.SetToken("xoxb-secret")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("xoxb-secret\n"), 0o600))
+
+	// A profile's token file replaces the top-level token
+	config := writeConfig(t, "slacker.yaml", "token: xoxb-other\nprofiles:\n  prod:\n    token-file: "+tokenFile+"\n")
+	_, err := runSlackReportWithToken(t, fake, nil, "--config", config, "--profile", "prod", "../examples/full.json")
+	require.NoError(t, err)
+
+	// As does a token file flag
+	config = writeConfig(t, "slacker.yaml", "token: xoxb-other\n")
+	_, err = runSlackReportWithToken(t, fake, []string{"--token-file", tokenFile}, "--config", config, "../examples/full.json")
+	require.NoError(t, err)
+
+	// But the same source can only give one
+	config = writeConfig(t, "slacker.yaml", "token: xoxb-other\ntoken-file: "+tokenFile+"\n")
+	_, err = runSlackReportWithToken(t, fake, nil, "--config", config, "../examples/full.json")
+	assert.ErrorContains(err, "Flags '--token', '--token-file' cannot be used together")
+
+	_, err = runSlackReportWithToken(t, fake, []string{"--token", "xoxb-secret", "--token-file", tokenFile}, "../examples/full.json")
+	assert.ErrorContains(err, "Flags '--token', '--token-file' cannot be used together")
+
+	_, err = runSlackReportWithToken(t, fake, nil, "../examples/full.json")
+	assert.ErrorContains(err, "Required flag not provided: one of token, token-file, token-fd")
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"dsab.slacker/report"
+	"dsab.slacker/slacknotify"
+)
+
+const (
+	FetchFlagDate = "date"
+)
+
+// The fetch flags share their names with the `slack-report` flags already bound to viper, so
+// are read with `stringFlag` & `intFlag`, which still fall back to env vars & the config file
+func init() {
+	FetchCmd.Flags().String(SlackFlagChannel, "", "[REQUIRED] Slack channel name or ID the report was sent to")
+	FetchCmd.Flags().String(SlackFlagToken, "", "[REQUIRED unless --token-file or --token-fd] Slack API token to use")
+	FetchCmd.Flags().String(SlackFlagTokenFile, "", "File to read the Slack API token from, or '-' for stdin, instead of --token")
+	FetchCmd.Flags().Int(SlackFlagTokenFd, 0, "File descriptor to read the Slack API token from, eg. 3 with '3< token', instead of --token")
+	FetchCmd.Flags().String(SlackFlagReportKind, "", "Kind of report to fetch (empty for the default)")
+	FetchCmd.Flags().String(FetchFlagDate, time.Now().Format(report.DateFormat), "Date of the report to fetch, in dd-mm-yyyy format")
+	FetchCmd.Flags().String(SlackFlagChannelCache, slacknotify.DefaultChannelCachePath(), "File used to cache channel name to ID lookups (empty to disable)")
+	FetchCmd.Flags().Int(SlackFlagLookupMaxPages, slacknotify.DefaultMaxPages, "Maximum pages of channel history or thread replies searched")
+
+	FetchCmd.Flags().String(SlackFlagApiUrl, "", "Slack Web API URL, for testing")
+	FetchCmd.Flags().MarkHidden(SlackFlagApiUrl)
+}
+
+var FetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetches a report previously sent to Slack, as report JSON",
+
+	Args: cobra.NoArgs,
+	Long: `Reconstructs the report JSON sent for a date from the metadata embedded in the summary
+report & its environment replies, writing it to stdout.
+
+Fails if the report can't be fully reconstructed, eg. as an environment was too large to
+embed, rather than writing a partial report.`,
+	Example: `# Fetch today's report
+slacker fetch --channel alerts --token redacted
+
+# Fetch the report for a specific date
+slacker fetch --channel alerts --token redacted --date 03-01-2023 > report.json`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			channel  = stringFlag(cmd, SlackFlagChannel)
+			date     = stringFlag(cmd, FetchFlagDate)
+			maxPages = intFlag(cmd, SlackFlagLookupMaxPages)
+			options  = slackOptions(stringFlag(cmd, SlackFlagApiUrl))
+		)
+
+		if channel == "" {
+			return fmt.Errorf("--%s is required", SlackFlagChannel)
+		}
+
+		tokenSource, err := buildTokenSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		scopes := []string{"channels:history|groups:history"}
+		if !slacknotify.IsChannelId(channel) {
+			scopes = append(scopes, slacknotify.ResolveScopes...)
+		}
+
+		auth, err := slacknotify.NewTokenValidator(tokenSource, options...).Validate(ctx, scopes...)
+		if err != nil {
+			return fmt.Errorf("invalid token: %v", err)
+		}
+
+		resolver, err := slacknotify.NewChannelResolver(tokenSource, options...)
+		if err != nil {
+			return err
+		}
+		channel, err = resolver.
+			WithCache(stringFlag(cmd, SlackFlagChannelCache), auth.TeamID).
+			Resolve(ctx, channel)
+		if err != nil {
+			return fmt.Errorf("could not resolve channel: %v", err)
+		}
+
+		finder, err := slacknotify.NewSlackReportFinder(tokenSource, channel, options...)
+		if err != nil {
+			return err
+		}
+		reportJson, err := finder.
+			WithKind(stringFlag(cmd, SlackFlagReportKind)).
+			WithMaxPages(maxPages).
+			FetchReport(ctx, date)
+		if err != nil {
+			return fmt.Errorf("could not fetch report: %v", err)
+		}
+		if reportJson == nil {
+			return fmt.Errorf("no report found for %s", date)
+		}
+
+		bytes, err := json.MarshalIndent(reportJson, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+		return err
+	},
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+	"dsab.slacker/report"
+)
+
+// runFetch runs `slacker fetch` against the fake Slack server, returning stdout
+func runFetch(t *testing.T, fake *fakeslack.Server, args ...string) (string, error) {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(FetchCmd.Flags())
+	// The fetch flags fall back to the `slack-report` flags bound to viper
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{
+		"fetch",
+		"--channel", testChannel,
+		"--token", "xoxb-test",
+		"--slack-api-url", fake.APIURL(),
+		"--channel-cache", "",
+	}, args...))
+
+	err := RootCmd.Execute()
+	return stdout.String(), err
+}
+
+func TestFetchReconstructsSentReport(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	stdout, err := runFetch(t, fake)
+	require.NoError(t, err)
+
+	sent, err := os.ReadFile("../examples/full.json")
+	require.NoError(t, err)
+
+	fetched, err := report.FromJson([]byte(stdout))
+	require.NoError(t, err)
+	expected, err := report.FromJson(sent)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, fetched)
+}
+
+func TestFetchIncompleteReportFails(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReport(t, fake, writeLargeReport(t))
+	require.NoError(t, err)
+
+	stdout, err := runFetch(t, fake)
+	assert.ErrorContains(t, err, "environments dev1 couldn't be read back")
+	assert.NotContains(t, stdout, `"environments"`)
+}
+
+func TestFetchMissingReportFails(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	_, err := runFetch(t, fake, "--date", time.Now().AddDate(0, 0, -3).Format(report.DateFormat))
+	assert.ErrorContains(t, err, "no report found")
+}
+
+func TestFetchOnlyFindsReportsOfKind(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReport(t, fake, "--report-kind", "smoke", "../examples/full.json")
+	require.NoError(t, err)
+
+	_, err = runFetch(t, fake)
+	assert.ErrorContains(t, err, "no report found")
+
+	stdout, err := runFetch(t, fake, "--report-kind", "smoke")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, `"dev1"`)
+}
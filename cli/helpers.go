@@ -3,7 +3,10 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
@@ -20,3 +23,115 @@ func requireFlags(flags ...string) error {
 
 	return errors.Join(errs...)
 }
+
+// rejectFlags ensures that none of the provided `flags` are supplied to viper, as they are
+// incompatible with another flag described by `reason`
+func rejectFlags(reason string, flags ...string) error {
+	var errs []error
+
+	for _, flag := range flags {
+		if viper.IsSet(flag) {
+			errs = append(errs, fmt.Errorf("Flag '--%v' cannot be used %s", flag, reason))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkUploadFlags ensures that at least one failure is shown when `uploadThreshold` is
+// set, as showing none would show every failure, ignoring the threshold
+func checkUploadFlags(uploadThreshold int, uploadTopFailures int) error {
+	if uploadThreshold > 0 && uploadTopFailures < 1 {
+		return fmt.Errorf("Flag '--%s' must be at least 1 with '--%s'", SlackFlagUploadTopFailures, SlackFlagUploadThreshold)
+	}
+	return nil
+}
+
+// requireOneFlag ensures that one of the provided `flags` of `cmd` is supplied, as they are
+// alternative ways of providing the same value, see `chooseFlag`
+func requireOneFlag(cmd *cobra.Command, flags ...string) error {
+	flag, err := chooseFlag(cmd, flags...)
+	if err != nil {
+		return err
+	}
+	if flag == "" {
+		return fmt.Errorf("Required flag not provided: one of %v", strings.Join(flags, ", "))
+	}
+
+	return nil
+}
+
+// chooseFlag returns which of the alternative `flags` of `cmd` is supplied, or empty if none
+// are. The most specific source supplying any of them wins: flags, then env vars, then the
+// config file, where a profile's settings already replace the top-level ones. Only supplying
+// more than one from the same source is an error
+func chooseFlag(cmd *cobra.Command, flags ...string) (string, error) {
+	sources := []func(flag string) bool{
+		cmd.Flags().Changed,
+		func(flag string) bool { return os.Getenv(envName(flag)) != "" },
+		viper.InConfig,
+	}
+
+	for _, isSet := range sources {
+		var set []string
+		for _, flag := range flags {
+			if isSet(flag) {
+				set = append(set, flag)
+			}
+		}
+
+		switch len(set) {
+		case 0:
+			continue
+		case 1:
+			return set[0], nil
+		default:
+			for i, flag := range set {
+				set[i] = "'--" + flag + "'"
+			}
+			return "", fmt.Errorf("Flags %s cannot be used together", strings.Join(set, ", "))
+		}
+	}
+
+	return "", nil
+}
+
+// envName is the env var `flag` is read from, when bound with `viper.BindEnv`
+func envName(flag string) string {
+	return strings.ToUpper(envKeyReplacer.Replace(flag))
+}
+
+// stringFlag reads the string `flag` of `cmd`, falling back to viper when it isn't set. This
+// is used by commands whose flags share their names with the `slack-report` flags bound to
+// viper, so that they can still be provided via. env vars & the config file
+func stringFlag(cmd *cobra.Command, flag string) string {
+	value, _ := cmd.Flags().GetString(flag)
+
+	if !cmd.Flags().Changed(flag) && viper.IsSet(flag) {
+		return viper.GetString(flag)
+	}
+
+	return value
+}
+
+// intFlag reads the int `flag` of `cmd`, falling back to viper like `stringFlag`
+func intFlag(cmd *cobra.Command, flag string) int {
+	value, _ := cmd.Flags().GetInt(flag)
+
+	if !cmd.Flags().Changed(flag) && viper.IsSet(flag) {
+		return viper.GetInt(flag)
+	}
+
+	return value
+}
+
+// boolFlag reads the bool `flag` of `cmd`, falling back to viper like `stringFlag`
+func boolFlag(cmd *cobra.Command, flag string) bool {
+	value, _ := cmd.Flags().GetBool(flag)
+
+	if !cmd.Flags().Changed(flag) && viper.IsSet(flag) {
+		return viper.GetBool(flag)
+	}
+
+	return value
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"dsab.slacker/history"
+)
+
+const (
+	HistoryFlagDays = "days"
+	HistoryFlagTop  = "top"
+)
+
+// OutputText is the human-readable table output of the history commands
+const OutputText = "text"
+
+// The history flags are read with `stringFlag` & `intFlag`, as `--history-dir` &
+// `--report-kind` share their names with the `slack-report` flags bound to viper, except for
+// `--output`, whose formats differ from those of `slack-report`, so can't be set in the
+// config file
+func init() {
+	HistoryCmd.PersistentFlags().String(SlackFlagHistoryDir, "", "[REQUIRED] Directory the report history is recorded in")
+	HistoryCmd.PersistentFlags().String(SlackFlagReportKind, "", "Kind of report to query (empty for the default)")
+	HistoryCmd.PersistentFlags().Int(HistoryFlagDays, 0, "Only include reports within this many days of the latest report (0 for all)")
+	HistoryCmd.PersistentFlags().StringP(SlackFlagOutput, "o", OutputText, "Output format: text or json")
+
+	HistoryNamespacesCmd.Flags().Int(HistoryFlagTop, 10, "Number of namespaces to list (0 for all)")
+
+	HistoryCmd.AddCommand(HistoryRunsCmd)
+	HistoryCmd.AddCommand(HistoryErrorsCmd)
+	HistoryCmd.AddCommand(HistoryStreaksCmd)
+	HistoryCmd.AddCommand(HistoryMttrCmd)
+	HistoryCmd.AddCommand(HistoryNamespacesCmd)
+}
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Queries the local history of reports sent",
+
+	Long: `Queries the local history of reports recorded by 'slacker slack-report --history-dir'.
+
+Each report sent is appended to 'reports.jsonl' in the history directory. When a report
+is re-sent for the same date, the latest one is used. Each kind of report is queried
+separately, with '--report-kind'.`,
+	Example: `# List the reports sent in the last 30 days
+slacker history runs --history-dir ~/.slacker --days 30
+
+# Show how long each environment has been healthy, degraded or unhealthy
+slacker history streaks --history-dir ~/.slacker
+
+# Using env vars for config instead of CLI flags
+HISTORY_DIR=~/.slacker slacker history mttr`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var HistoryRunsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Lists the reports sent, with their unhealthy environments & errors",
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := readHistory(cmd)
+		if err != nil {
+			return err
+		}
+
+		runs := history.Runs(entries)
+
+		return writeHistory(cmd, runs, func(w io.Writer) {
+			fmt.Fprintln(w, "DATE\tENVIRONMENTS\tUNHEALTHY\tERRORS")
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", run.Date, run.Environments, run.Unhealthy, run.Errors)
+			}
+		})
+	},
+}
+
+var HistoryErrorsCmd = &cobra.Command{
+	Use:   "errors",
+	Short: "Shows the errors in each environment over time",
+	Long: `Shows the errors in each environment over time. Environments that hadn't completed on
+a date are shown as '-'.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := readHistory(cmd)
+		if err != nil {
+			return err
+		}
+
+		counts := history.ErrorCounts(entries)
+		envs := history.Environments(entries)
+
+		return writeHistory(cmd, counts, func(w io.Writer) {
+			fmt.Fprintf(w, "DATE\t%s\n", strings.Join(envs, "\t"))
+			for _, daily := range counts {
+				columns := []string{daily.Date}
+				for _, env := range envs {
+					if errors, ok := daily.Errors[env]; ok {
+						columns = append(columns, strconv.Itoa(errors))
+					} else {
+						columns = append(columns, "-")
+					}
+				}
+				fmt.Fprintln(w, strings.Join(columns, "\t"))
+			}
+		})
+	},
+}
+
+var HistoryStreaksCmd = &cobra.Command{
+	Use:   "streaks",
+	Short: "Shows how many consecutive reports each environment has been healthy, degraded or unhealthy",
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := readHistory(cmd)
+		if err != nil {
+			return err
+		}
+
+		streaks := history.Streaks(entries)
+
+		return writeHistory(cmd, streaks, func(w io.Writer) {
+			fmt.Fprintln(w, "ENVIRONMENT\tSTATE\tRUNS\tSINCE")
+			for _, streak := range streaks {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", streak.Environment, streak.Health, streak.Runs, streak.Since)
+			}
+		})
+	},
+}
+
+var HistoryMttrCmd = &cobra.Command{
+	Use:   "mttr",
+	Short: "Shows the mean time to recovery of each environment",
+	Long: `Shows the mean time to recovery of each environment: the mean time from the first
+degraded or unhealthy report of an incident to the next healthy report. Incidents that are still
+ongoing aren't included in the mean.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := readHistory(cmd)
+		if err != nil {
+			return err
+		}
+
+		recoveries := history.Recoveries(entries)
+
+		return writeHistory(cmd, recoveries, func(w io.Writer) {
+			fmt.Fprintln(w, "ENVIRONMENT\tINCIDENTS\tRECOVERED\tMTTR\tONGOING")
+			for _, recovery := range recoveries {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%t\n",
+					recovery.Environment,
+					recovery.Incidents,
+					recovery.Recovered,
+					formatDays(recovery.MeanTimeToRecovery, recovery.Recovered > 0),
+					recovery.Ongoing,
+				)
+			}
+		})
+	},
+}
+
+var HistoryNamespacesCmd = &cobra.Command{
+	Use:   "namespaces",
+	Short: "Lists the namespaces that fail most often",
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := readHistory(cmd)
+		if err != nil {
+			return err
+		}
+
+		namespaces := history.TopNamespaces(entries)
+		if top := intFlag(cmd, HistoryFlagTop); top > 0 && len(namespaces) > top {
+			namespaces = namespaces[:top]
+		}
+
+		return writeHistory(cmd, namespaces, func(w io.Writer) {
+			fmt.Fprintln(w, "ENVIRONMENT\tNAMESPACE\tRUNS\tFAILURES")
+			for _, ns := range namespaces {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", ns.Environment, ns.Namespace, ns.Runs, ns.Failures)
+			}
+		})
+	},
+}
+
+// readHistory reads the history entries selected by the history flags
+func readHistory(cmd *cobra.Command) ([]history.Entry, error) {
+	dir := stringFlag(cmd, SlackFlagHistoryDir)
+	if dir == "" {
+		return nil, fmt.Errorf("Required flag not provided: %v", SlackFlagHistoryDir)
+	}
+
+	entries, err := history.NewFileStore(dir).Entries(stringFlag(cmd, SlackFlagReportKind))
+	if err != nil {
+		return nil, fmt.Errorf("could not read history: %v", err)
+	}
+
+	return history.Window(entries, intFlag(cmd, HistoryFlagDays)), nil
+}
+
+// writeHistory writes `v` as JSON, or as a table written by `writeTable`, depending on the
+// output flag
+func writeHistory(cmd *cobra.Command, v any, writeTable func(w io.Writer)) error {
+	switch format, _ := cmd.Flags().GetString(SlackFlagOutput); format {
+	case OutputText:
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		writeTable(w)
+		return w.Flush()
+
+	case OutputJson:
+		bytes, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+		return err
+
+	default:
+		return fmt.Errorf("unknown output format '%s'", format)
+	}
+}
+
+// formatDays formats a duration in days, eg. `1.5d`, or `-` if it isn't `known`
+func formatDays(d time.Duration, known bool) string {
+	if !known {
+		return "-"
+	}
+	return strconv.FormatFloat(d.Hours()/24, 'f', -1, 64) + "d"
+}
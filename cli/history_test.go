@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+)
+
+// runHistory runs `slacker history`, returning its stdout
+func runHistory(t *testing.T, args ...string) string {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(HistoryCmd.PersistentFlags())
+	for _, cmd := range HistoryCmd.Commands() {
+		resetFlags(cmd.Flags())
+	}
+	// The history flags fall back to the `slack-report` flags bound to viper
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{"history"}, args...))
+	require.NoError(t, RootCmd.Execute())
+
+	return stdout.String()
+}
+
+func TestSlackReportRecordsHistory(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	dir := t.TempDir()
+
+	_, err := runSlackReport(t, fake, "--history-dir", dir, "--report-date", "20-09-2023", "../examples/full.json")
+	require.NoError(t, err)
+	_, err = runSlackReport(t, fake, "--history-dir", dir, "--report-date", "21-09-2023", "../examples/full.json")
+	require.NoError(t, err)
+
+	// The trend is read from the history rather than Slack
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 2)
+	summary, err := json.Marshal(msgs[1].Blocks)
+	require.NoError(t, err)
+	assert.Contains(string(summary), "(no change vs yesterday, 2 days in a row)")
+
+	assert.Equal(`DATE        ENVIRONMENTS  UNHEALTHY  ERRORS
+20-09-2023  3             2          22
+21-09-2023  3             2          22
+`, runHistory(t, "runs", "--history-dir", dir))
+
+	assert.Equal(`DATE        ENVIRONMENTS  UNHEALTHY  ERRORS
+21-09-2023  3             2          22
+`, runHistory(t, "runs", "--history-dir", dir, "--days", "1"))
+
+	assert.Contains(runHistory(t, "streaks", "--history-dir", dir, "-o", "json"), `"since": "20-09-2023"`)
+}
+
+func TestSlackReportDryRunDoesNotRecordHistory(t *testing.T) {
+	fake := fakeslack.New(t)
+	dir := t.TempDir()
+
+	_, err := runSlackReport(t, fake, "--history-dir", dir, "--dry-run", "../examples/full.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, "[]\n", runHistory(t, "runs", "--history-dir", dir, "-o", "json"))
+}
+
+func TestSlackReportRecordsHistoryPerKind(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	dir := t.TempDir()
+
+	_, err := runSlackReport(t, fake, "--history-dir", dir, "--report-date", "20-09-2023", "../examples/full.json")
+	require.NoError(t, err)
+	_, err = runSlackReport(t, fake, "--history-dir", dir, "--report-date", "21-09-2023", "--report-kind", "smoke", "../examples/minimal.json")
+	require.NoError(t, err)
+
+	// The smoke report's trend doesn't include the default kind's report
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 2)
+	summary, err := json.Marshal(msgs[1].Blocks)
+	require.NoError(t, err)
+	assert.NotContains(string(summary), "vs yesterday")
+
+	assert.Equal(`DATE        ENVIRONMENTS  UNHEALTHY  ERRORS
+20-09-2023  3             2          22
+`, runHistory(t, "runs", "--history-dir", dir))
+
+	assert.NotContains(runHistory(t, "runs", "--history-dir", dir, "--report-kind", "smoke"), "20-09-2023")
+	assert.Contains(runHistory(t, "runs", "--history-dir", dir, "--report-kind", "smoke"), "21-09-2023")
+}
+
+func TestHistoryReadsEnvVars(t *testing.T) {
+	fake := fakeslack.New(t)
+	dir := t.TempDir()
+
+	_, err := runSlackReport(t, fake, "--history-dir", dir, "--report-date", "20-09-2023", "../examples/full.json")
+	require.NoError(t, err)
+
+	// Dashes in flag names are underscores in env var names
+	t.Setenv("HISTORY_DIR", dir)
+	assert.Contains(t, runHistory(t, "runs"), "20-09-2023")
+
+	// Flags that aren't settings ignore generic env vars, eg. from CI
+	t.Setenv("TOP", "1")
+	assert.Contains(t, runHistory(t, "namespaces"), "dev2")
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions taken for each message sent
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+)
+
+// Output formats selectable with `--output`
+const (
+	OutputJson = "json"
+	OutputYaml = "yaml"
+	OutputNone = "none"
+)
+
+// Output is the machine-readable result of sending a report
+type Output struct {
+	Channel      string              `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Summary      MessageOutput       `json:"summary" yaml:"summary"`
+	Environments []EnvironmentOutput `json:"environments" yaml:"environments"`
+}
+
+// MessageOutput describes a message that was sent
+type MessageOutput struct {
+	Ts        string `json:"ts,omitempty" yaml:"ts,omitempty"`
+	Permalink string `json:"permalink,omitempty" yaml:"permalink,omitempty"`
+	Action    string `json:"action" yaml:"action"`
+}
+
+// EnvironmentOutput describes the reply sent for an environment
+type EnvironmentOutput struct {
+	Environment   string `json:"environment" yaml:"environment"`
+	MessageOutput `yaml:",inline"`
+}
+
+// writeOutput writes `output` to `w` in the given `format`
+func writeOutput(w io.Writer, format string, output Output) error {
+	switch format {
+	case OutputJson:
+		bytes, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(bytes))
+		return err
+
+	case OutputYaml:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(output); err != nil {
+			return err
+		}
+		return encoder.Close()
+
+	case OutputNone:
+		return nil
+
+	default:
+		return fmt.Errorf("unknown output format '%s'", format)
+	}
+}
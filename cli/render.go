@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"dsab.slacker/report"
+	"dsab.slacker/slacknotify"
+)
+
+const (
+	RenderFlagOutputDir   = "output-dir"
+	RenderFlagBuilderUrls = "builder-urls"
+)
+
+// The render flags share their names with the `slack-report` flags already bound to viper, so
+// are read with `stringFlag` & `intFlag`, which still fall back to env vars & the config file
+func init() {
+	RenderCmd.Flags().String(SlackFlagReportBaseUrl, "", "[REQUIRED] Base URL used to build links to reports")
+
+	RenderCmd.Flags().String(SlackFlagReportDate, time.Now().Format(report.DateFormat), "Report date in dd-mm-yyyy format")
+	RenderCmd.Flags().String(SlackFlagReportKind, "", "Kind of report, recorded in the summary metadata (empty for the default)")
+	RenderCmd.Flags().String(SlackFlagLearnMoreUrl, "", "URL for the 'Learn more' button (hidden if empty)")
+	RenderCmd.Flags().Int(SlackFlagUploadThreshold, 0, "Render environments with more failures than this as if their full report was uploaded (0 to disable)")
+	RenderCmd.Flags().Int(SlackFlagUploadTopFailures, 20, "Number of failures shown when the full report is uploaded")
+	RenderCmd.Flags().String(SlackFlagPreviousReportFile, "", "Previous report JSON to compare with, highlighting new & resolved failures")
+	RenderCmd.Flags().String(SlackFlagHistoryDir, "", "Directory of the report history used to show each environment's trend")
+	RenderCmd.Flags().Int(SlackFlagTrendDays, 7, "Number of days of each environment's trend shown in the summary (0 to disable)")
+	RenderCmd.Flags().String(SlackFlagTemplateDir, "", "Directory of templates overriding the default message layout")
+	RenderCmd.Flags().String(SlackFlagOwnersFile, "", "YAML or JSON file of rules assigning owners to failures, who are mentioned in the thread")
+
+	RenderCmd.Flags().String(RenderFlagOutputDir, "", "Write each message to a separate file in this directory, instead of to stdout")
+	RenderCmd.Flags().Bool(RenderFlagBuilderUrls, false, "Include a Block Kit Builder preview link for each message")
+}
+
+var RenderCmd = &cobra.Command{
+	Use:   "render [FILE]",
+	Short: "Renders the Slack messages for a report JSON document without sending them",
+
+	Args: cobra.ExactArgs(1),
+	Long: `Renders the exact payloads (blocks, attachments & metadata) that 'slacker slack-report'
+would send for a new report: the summary report, then a reply for each environment,
+each followed by any continuations.
+
+The messages are written to stdout as a JSON array, or with '--output-dir' to a file per
+message named after the message, eg. 'summary.json' or 'environment-dev1.json'.
+
+The previous report button relies on looking up the previous report in Slack, so is never
+rendered. Environment trends are only rendered from the local history, with '--history-dir'.
+Owners are only mentioned when given as Slack user or user group IDs, as emails & handles
+can't be looked up.`,
+	Example: `# Render a report to stdout
+slacker render --report-base-url https://my-reports report.json
+
+# Render a report to a directory, printing a Block Kit Builder link for each message
+slacker render --report-base-url https://my-reports --output-dir out --builder-urls report.json
+
+# Preview a custom message layout
+slacker render --report-base-url https://my-reports --template-dir templates report.json`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			reportBaseUrl      = stringFlag(cmd, SlackFlagReportBaseUrl)
+			reportDate         = stringFlag(cmd, SlackFlagReportDate)
+			learnMoreUrl       = stringFlag(cmd, SlackFlagLearnMoreUrl)
+			reportKind         = stringFlag(cmd, SlackFlagReportKind)
+			uploadThreshold    = intFlag(cmd, SlackFlagUploadThreshold)
+			uploadTopFailures  = intFlag(cmd, SlackFlagUploadTopFailures)
+			previousReportFile = stringFlag(cmd, SlackFlagPreviousReportFile)
+			historyDir         = stringFlag(cmd, SlackFlagHistoryDir)
+			trendDays          = intFlag(cmd, SlackFlagTrendDays)
+			templateDir        = stringFlag(cmd, SlackFlagTemplateDir)
+			ownersFile         = stringFlag(cmd, SlackFlagOwnersFile)
+			outputDir          = stringFlag(cmd, RenderFlagOutputDir)
+			builderUrls        = boolFlag(cmd, RenderFlagBuilderUrls)
+		)
+
+		if reportBaseUrl == "" {
+			return fmt.Errorf("Required flag not provided: %v", SlackFlagReportBaseUrl)
+		}
+		if err := checkUploadFlags(uploadThreshold, uploadTopFailures); err != nil {
+			return err
+		}
+
+		reportJson, err := readJsonReportFromFileOrStdin(cmd.Context(), cmd, args)
+		if err != nil {
+			return fmt.Errorf("could not read json report: %v", err)
+		}
+
+		previousReport, err := readPreviousReport(previousReportFile)
+		if err != nil {
+			return fmt.Errorf("could not read previous json report: %v", err)
+		}
+
+		templates, err := loadTemplates(templateDir)
+		if err != nil {
+			return fmt.Errorf("could not load templates: %v", err)
+		}
+
+		ownerRules, err := readOwnerRules(ownersFile)
+		if err != nil {
+			return fmt.Errorf("could not read owners file: %v", err)
+		}
+
+		reportConfig := report.ReportConfig{
+			ReportDate:     reportDate,
+			BaseUrl:        reportBaseUrl,
+			LearnMoreUrl:   learnMoreUrl,
+			Kind:           reportKind,
+			PreviousReport: previousReport,
+			OwnerRules:     append(slices.Clip(reportJson.Owners), ownerRules...),
+		}
+
+		// Without access to Slack, owners can't be looked up by email, so only those given as
+		// IDs or mentions are mentioned
+		reportConfig.Mentions = slacknotify.StaticMentions(slacknotify.ReportOwners(*reportJson, reportConfig.OwnerRules))
+
+		// Without access to Slack, trends can only be shown from the local history
+		if historyDir != "" {
+			lookupPastReports(cmd.Context(), &reportConfig, slacknotify.NewNoOpReportFinder(), historyDir, trendDays)
+		}
+
+		rendered, err := slacknotify.NewRenderer(reportConfig).
+			WithTemplates(templates).
+			WithFileUpload(uploadThreshold, uploadTopFailures).
+			Render(*reportJson)
+		if err != nil {
+			return err
+		}
+
+		if builderUrls {
+			for i, msg := range rendered {
+				if rendered[i].BuilderUrl, err = msg.Message.BuilderUrl(); err != nil {
+					return fmt.Errorf("could not build Block Kit Builder URL for %s: %v", msg.Name, err)
+				}
+			}
+		}
+
+		if outputDir != "" {
+			return writeRenderedMessages(cmd, outputDir, rendered)
+		}
+
+		bytes, err := json.MarshalIndent(rendered, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+		return err
+	},
+}
+
+// writeRenderedMessages writes each message's payload to `<name>.json` in `outputDir`,
+// listing the files written, and any Block Kit Builder links, on stdout
+func writeRenderedMessages(cmd *cobra.Command, outputDir string, rendered []slacknotify.RenderedMessage) error {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("could not create output directory: %v", err)
+	}
+
+	for _, msg := range rendered {
+		bytes, err := json.MarshalIndent(msg.Message, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		filename := filepath.Join(outputDir, msg.Name+".json")
+		if err := os.WriteFile(filename, append(bytes, '\n'), 0o644); err != nil {
+			return fmt.Errorf("could not write %s: %v", filename, err)
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), filename)
+		if msg.BuilderUrl != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", msg.BuilderUrl)
+		}
+	}
+
+	return nil
+}
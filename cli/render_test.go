@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+)
+
+// runRender runs `slacker render`, returning stdout
+func runRender(t *testing.T, args ...string) string {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(RenderCmd.Flags())
+	// The render flags fall back to the `slack-report` flags bound to viper
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{"render", "--report-base-url", "https://reports.example.com"}, args...))
+	require.NoError(t, RootCmd.Execute())
+
+	return stdout.String()
+}
+
+func TestRenderMatchesSentMessages(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	rendered := []struct {
+		Name    string `json:"name"`
+		Message struct {
+			Blocks      json.RawMessage `json:"blocks"`
+			Attachments json.RawMessage `json:"attachments"`
+			Metadata    json.RawMessage `json:"metadata"`
+		} `json:"message"`
+	}{}
+	require.NoError(t, json.Unmarshal([]byte(runRender(t, "../examples/full.json")), &rendered))
+
+	summary := fake.Messages(testChannel)[0]
+	replies := fake.Replies(testChannel, summary.Timestamp)
+	sent := append([]interface{}{summary.Blocks}, replies[0].Attachments, replies[1].Attachments, replies[2].Attachments)
+
+	require.Len(t, rendered, 4)
+	assert.Equal([]string{"summary", "environment-dev1", "environment-dev2", "environment-dev3"},
+		[]string{rendered[0].Name, rendered[1].Name, rendered[2].Name, rendered[3].Name})
+
+	for i, content := range []json.RawMessage{rendered[0].Message.Blocks, rendered[1].Message.Attachments, rendered[2].Message.Attachments, rendered[3].Message.Attachments} {
+		expected, err := json.Marshal(sent[i])
+		require.NoError(t, err)
+		assert.JSONEq(string(expected), string(content), rendered[i].Name)
+	}
+}
+
+func TestRenderToDirectory(t *testing.T) {
+	assert := assert.New(t)
+	outputDir := t.TempDir()
+
+	stdout := runRender(t, "--output-dir", outputDir, "--builder-urls", "../examples/full.json")
+
+	for _, name := range []string{"summary", "environment-dev1", "environment-dev2"} {
+		filename := filepath.Join(outputDir, name+".json")
+		assert.FileExists(filename)
+		assert.Contains(stdout, filename)
+
+		bytes, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Contains(string(bytes), `"metadata"`)
+	}
+	assert.Contains(stdout, "https://app.slack.com/block-kit-builder/#")
+}
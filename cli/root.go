@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -8,15 +10,33 @@ import (
 
 const (
 	RootFlagVerbose = "verbose"
+	RootFlagConfig  = "config"
+	RootFlagProfile = "profile"
 )
 
+// envKeyReplacer maps flag names to the env vars they can be provided with, eg.
+// `--report-base-url` to `REPORT_BASE_URL`
+var envKeyReplacer = strings.NewReplacer("-", "_")
+
 func init() {
-	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 
 	RootCmd.PersistentFlags().Bool(RootFlagVerbose, false, "Show Debug log output")
 	viper.BindPFlag(RootFlagVerbose, RootCmd.PersistentFlags().Lookup(RootFlagVerbose))
 
+	RootCmd.PersistentFlags().String(RootFlagConfig, "", "Config file (YAML or TOML) providing defaults for any flag, see 'slacker config'")
+	viper.BindPFlag(RootFlagConfig, RootCmd.PersistentFlags().Lookup(RootFlagConfig))
+
+	RootCmd.PersistentFlags().String(RootFlagProfile, "", "Profile of the config file to use, overriding its top-level settings")
+	viper.BindPFlag(RootFlagProfile, RootCmd.PersistentFlags().Lookup(RootFlagProfile))
+
 	RootCmd.AddCommand(SlackCmd)
+	RootCmd.AddCommand(RenderCmd)
+	RootCmd.AddCommand(FetchCmd)
+	RootCmd.AddCommand(HistoryCmd)
+	RootCmd.AddCommand(ValidateCmd)
+	RootCmd.AddCommand(SchemaCmd)
+	RootCmd.AddCommand(ConfigCmd)
 }
 
 var RootCmd = &cobra.Command{
@@ -25,12 +45,18 @@ var RootCmd = &cobra.Command{
 
 	Args: cobra.ExactArgs(1),
 
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd.Root(), viper.GetString(RootFlagConfig), viper.GetString(RootFlagProfile)); err != nil {
+			return err
+		}
+
 		verbose := viper.GetBool(RootFlagVerbose)
 
 		if verbose {
 			log.SetLevel(log.DebugLevel)
 		}
+
+		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return cmd.Help()
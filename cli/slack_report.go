@@ -1,16 +1,26 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 
+	"dsab.slacker/history"
 	"dsab.slacker/report"
 	"dsab.slacker/slacknotify"
 )
@@ -18,6 +28,8 @@ import (
 const (
 	SlackFlagChannel            = "channel"
 	SlackFlagToken              = "token"
+	SlackFlagTokenFile          = "token-file"
+	SlackFlagTokenFd            = "token-fd"
 	SlackFlagReportDate         = "report-date"
 	SlackFlagReportBaseUrl      = "report-base-url"
 	SlackFlagUpdateEnvironments = "update-environments"
@@ -25,65 +37,178 @@ const (
 	SlackFlagDryRun             = "dry-run"
 	SlackFlagVerbose            = "verbose"
 	SlackFlagLookupLastReport   = "lookup-last-report"
+	SlackFlagLearnMoreUrl       = "learn-more-url"
+	SlackFlagPreviousReportDays = "previous-report-lookback-days"
+	SlackFlagPreviousReportFile = "previous-report-file"
+	SlackFlagUploadThreshold    = "upload-threshold"
+	SlackFlagUploadTopFailures  = "upload-top-failures"
+	SlackFlagWebhookUrl         = "webhook-url"
+	SlackFlagRetryMaxAttempts   = "retry-max-attempts"
+	SlackFlagRetryMaxElapsed    = "retry-max-elapsed"
+	SlackFlagTimeout            = "timeout"
+	SlackFlagLookupMaxPages     = "lookup-max-pages"
+	SlackFlagChannelCache       = "channel-cache"
+	SlackFlagOutput             = "output"
+	SlackFlagApiUrl             = "slack-api-url"
+	SlackFlagHistoryDir         = "history-dir"
+	SlackFlagTrendDays          = "trend-days"
+	SlackFlagTemplateDir        = "template-dir"
+	SlackFlagReportKind         = "report-kind"
+	SlackFlagOwnersFile         = "owners-file"
+	SlackFlagOwnerEmailDomain   = "owner-email-domain"
 )
 
 func init() {
-	viper.AutomaticEnv()
-
-	SlackCmd.Flags().String(SlackFlagChannel, "", "[REQUIRED] Slack channel name to send to")
+	SlackCmd.Flags().String(SlackFlagChannel, "", "[REQUIRED unless --webhook-url] Slack channel name or ID to send to")
 	viper.BindPFlag(SlackFlagChannel, SlackCmd.Flags().Lookup(SlackFlagChannel))
 
-	SlackCmd.Flags().String(SlackFlagToken, "", "[REQUIRED] Slack API token to use")
+	SlackCmd.Flags().String(SlackFlagChannelCache, slacknotify.DefaultChannelCachePath(), "File used to cache channel name to ID lookups (empty to disable)")
+	viper.BindPFlag(SlackFlagChannelCache, SlackCmd.Flags().Lookup(SlackFlagChannelCache))
+
+	SlackCmd.Flags().String(SlackFlagToken, "", "[REQUIRED unless --webhook-url, --token-file or --token-fd] Slack API token to use")
 	viper.BindPFlag(SlackFlagToken, SlackCmd.Flags().Lookup(SlackFlagToken))
 
+	SlackCmd.Flags().String(SlackFlagTokenFile, "", "File to read the Slack API token from, or '-' for stdin, instead of --token")
+	viper.BindPFlag(SlackFlagTokenFile, SlackCmd.Flags().Lookup(SlackFlagTokenFile))
+
+	SlackCmd.Flags().Int(SlackFlagTokenFd, 0, "File descriptor to read the Slack API token from, eg. 3 with '3< token', instead of --token")
+	viper.BindPFlag(SlackFlagTokenFd, SlackCmd.Flags().Lookup(SlackFlagTokenFd))
+
 	SlackCmd.Flags().String(SlackFlagReportBaseUrl, "", "[REQUIRED] Base URL used to build links to reports")
 	viper.BindPFlag(SlackFlagReportBaseUrl, SlackCmd.Flags().Lookup(SlackFlagReportBaseUrl))
 
+	SlackCmd.Flags().String(SlackFlagWebhookUrl, "", "Incoming webhook URL to send to, instead of using --token & --channel")
+	viper.BindPFlag(SlackFlagWebhookUrl, SlackCmd.Flags().Lookup(SlackFlagWebhookUrl))
+
+	SlackCmd.Flags().String(SlackFlagReportKind, "", "Kind of report, so that different kinds of report sent to the same channel don't find each other (empty for the default)")
+	viper.BindPFlag(SlackFlagReportKind, SlackCmd.Flags().Lookup(SlackFlagReportKind))
+
 	SlackCmd.Flags().Bool(SlackFlagUpdateEnvironments, true, "Whether to update existing environment messages")
 	viper.BindPFlag(SlackFlagUpdateEnvironments, SlackCmd.Flags().Lookup(SlackFlagUpdateEnvironments))
 
 	SlackCmd.Flags().String(SlackFlagUpdateMessageTs, "", "The TS of a message to update & reply to")
 	viper.BindPFlag(SlackFlagUpdateMessageTs, SlackCmd.Flags().Lookup(SlackFlagUpdateMessageTs))
 
-	SlackCmd.Flags().String(SlackFlagReportDate, time.Now().Format("02-01-2006"), "Report date in dd-mm-yyyy format")
+	SlackCmd.Flags().String(SlackFlagReportDate, time.Now().Format(report.DateFormat), "Report date in dd-mm-yyyy format")
 	viper.BindPFlag(SlackFlagReportDate, SlackCmd.Flags().Lookup(SlackFlagReportDate))
 
 	SlackCmd.Flags().Bool(SlackFlagLookupLastReport, false, "Look up the last report automatically")
 	viper.BindPFlag(SlackFlagLookupLastReport, SlackCmd.Flags().Lookup(SlackFlagLookupLastReport))
 
+	SlackCmd.Flags().String(SlackFlagLearnMoreUrl, "", "URL for the 'Learn more' button (hidden if empty)")
+	viper.BindPFlag(SlackFlagLearnMoreUrl, SlackCmd.Flags().Lookup(SlackFlagLearnMoreUrl))
+
+	SlackCmd.Flags().Int(SlackFlagPreviousReportDays, 7, "Number of days to search back for the previous report (0 to disable)")
+	viper.BindPFlag(SlackFlagPreviousReportDays, SlackCmd.Flags().Lookup(SlackFlagPreviousReportDays))
+
+	SlackCmd.Flags().String(SlackFlagPreviousReportFile, "", "Previous report JSON to compare with, highlighting new & resolved failures")
+	viper.BindPFlag(SlackFlagPreviousReportFile, SlackCmd.Flags().Lookup(SlackFlagPreviousReportFile))
+
+	SlackCmd.Flags().Int(SlackFlagUploadThreshold, 0, "Upload the full report as files for environments with more failures than this (0 to disable)")
+	viper.BindPFlag(SlackFlagUploadThreshold, SlackCmd.Flags().Lookup(SlackFlagUploadThreshold))
+
+	SlackCmd.Flags().Int(SlackFlagUploadTopFailures, 20, "Number of failures shown in the thread when the full report is uploaded")
+	viper.BindPFlag(SlackFlagUploadTopFailures, SlackCmd.Flags().Lookup(SlackFlagUploadTopFailures))
+
+	SlackCmd.Flags().Int(SlackFlagRetryMaxAttempts, slacknotify.DefaultRetryConfig().MaxAttempts, "Maximum number of attempts for each Slack API call (1 to disable retries)")
+	viper.BindPFlag(SlackFlagRetryMaxAttempts, SlackCmd.Flags().Lookup(SlackFlagRetryMaxAttempts))
+
+	SlackCmd.Flags().Duration(SlackFlagRetryMaxElapsed, slacknotify.DefaultRetryConfig().MaxElapsed, "Maximum time spent retrying each Slack API call")
+	viper.BindPFlag(SlackFlagRetryMaxElapsed, SlackCmd.Flags().Lookup(SlackFlagRetryMaxElapsed))
+
+	SlackCmd.Flags().Int(SlackFlagLookupMaxPages, slacknotify.DefaultMaxPages, "Maximum pages of channel history or thread replies searched when looking up reports")
+	viper.BindPFlag(SlackFlagLookupMaxPages, SlackCmd.Flags().Lookup(SlackFlagLookupMaxPages))
+
+	SlackCmd.Flags().Duration(SlackFlagTimeout, 5*time.Minute, "Maximum time for the whole run (0 to disable)")
+	viper.BindPFlag(SlackFlagTimeout, SlackCmd.Flags().Lookup(SlackFlagTimeout))
+
+	SlackCmd.Flags().String(SlackFlagHistoryDir, "", "Directory to record each report sent in, for 'slacker history' (empty to disable)")
+	viper.BindPFlag(SlackFlagHistoryDir, SlackCmd.Flags().Lookup(SlackFlagHistoryDir))
+
+	SlackCmd.Flags().Int(SlackFlagTrendDays, 7, "Number of days of each environment's trend shown in the summary (0 to disable)")
+	viper.BindPFlag(SlackFlagTrendDays, SlackCmd.Flags().Lookup(SlackFlagTrendDays))
+
+	SlackCmd.Flags().String(SlackFlagTemplateDir, "", "Directory of templates overriding the default message layout")
+	viper.BindPFlag(SlackFlagTemplateDir, SlackCmd.Flags().Lookup(SlackFlagTemplateDir))
+
+	SlackCmd.Flags().String(SlackFlagOwnersFile, "", "YAML or JSON file of rules assigning owners to failures, who are mentioned in the thread")
+	viper.BindPFlag(SlackFlagOwnersFile, SlackCmd.Flags().Lookup(SlackFlagOwnersFile))
+
+	SlackCmd.Flags().String(SlackFlagOwnerEmailDomain, "", "Email domain owners given as handles are looked up by, eg. 'example.com' for '@alice' (empty to only look up emails)")
+	viper.BindPFlag(SlackFlagOwnerEmailDomain, SlackCmd.Flags().Lookup(SlackFlagOwnerEmailDomain))
+
+	SlackCmd.Flags().StringP(SlackFlagOutput, "o", OutputJson, "Format of the result written to stdout: json, yaml or none")
+	viper.BindPFlag(SlackFlagOutput, SlackCmd.Flags().Lookup(SlackFlagOutput))
+
+	SlackCmd.Flags().String(SlackFlagApiUrl, "", "Base URL of the Slack Web API, for testing")
+	SlackCmd.Flags().MarkHidden(SlackFlagApiUrl)
+	viper.BindPFlag(SlackFlagApiUrl, SlackCmd.Flags().Lookup(SlackFlagApiUrl))
+
 	SlackCmd.Flags().Bool(SlackFlagDryRun, false, "Use dry-run mode")
 	viper.BindPFlag(SlackFlagDryRun, SlackCmd.Flags().Lookup(SlackFlagDryRun))
-}
 
-func readJsonReportFromFileOrStdin(cmd *cobra.Command, args []string) (*report.ReportJson, error) {
-	var (
-		reader io.Reader
-		err    error
-	)
+	// Only the `slack-report` flags can be provided as env vars, rather than every flag with
+	// `viper.AutomaticEnv`, so that generic env vars such as `DAYS` in CI don't change the
+	// flags of other commands
+	SlackCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		viper.BindEnv(f.Name)
+	})
+}
 
-	// This is validated by the command's `cobra.ExactArgs(0)`
+// readFileOrStdin reads the file named by the first argument, or stdin if it is `-`, giving
+// up on stdin once `ctx` is done
+func readFileOrStdin(ctx context.Context, cmd *cobra.Command, args []string) ([]byte, error) {
+	// This is validated by the command's `cobra.ExactArgs(1)`
 	filename := args[0]
 	if filename == "-" {
-		reader = cmd.InOrStdin()
-	} else {
-		reader, err = os.Open(filename)
-		if err != nil {
-			return nil, err
-		}
+		return readAll(ctx, cmd.InOrStdin())
 	}
 
-	bytes, err := io.ReadAll(reader)
-	return report.FromJson(bytes)
+	return os.ReadFile(filename)
+}
+
+// readAll reads `r` until EOF, or until `ctx` is done. A blocked read can't be interrupted,
+// so is left to finish in the background
+func readAll(ctx context.Context, r io.Reader) ([]byte, error) {
+	type result struct {
+		bytes []byte
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		bytes, err := io.ReadAll(r)
+		done <- result{bytes, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.bytes, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
-type Output struct {
-	ResponseTimestamp slacknotify.ResponseTimestamp
+// readJsonReportFromFileOrStdin reads & validates the report, returning every validation
+// error found
+func readJsonReportFromFileOrStdin(ctx context.Context, cmd *cobra.Command, args []string) (*report.ReportJson, error) {
+	bytes, err := readFileOrStdin(ctx, cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	reportJson, errs := report.Validate(bytes)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return reportJson, nil
 }
 
 // determineUpdate checks to see if either `--update-message-ts` or `--lookup-last-report`
 // have been set and will set the value of `update` either directly, or by looking up
 // the existing report via. the provided `reportFinder`
-func determineUpdate(updateMessageTs *slacknotify.ResponseTimestamp, reportFinder slacknotify.ReportFinder) error {
+func determineUpdate(ctx context.Context, updateMessageTs *slacknotify.ResponseTimestamp, reportFinder slacknotify.ReportFinder) error {
 	var (
 		updateMessageTsString = viper.GetString(SlackFlagUpdateMessageTs)
 		lookupLastReport      = viper.GetBool(SlackFlagLookupLastReport)
@@ -103,7 +228,7 @@ func determineUpdate(updateMessageTs *slacknotify.ResponseTimestamp, reportFinde
 		//
 	} else if lookupLastReport {
 		// Set the value of the provided `updateMessageTs` pointer to the the one that was looked up
-		r, err := reportFinder.FindReport(reportDate)
+		r, err := reportFinder.FindReport(ctx, reportDate)
 		if err != nil {
 			return fmt.Errorf("failed to look up last report: %v\n", err)
 		}
@@ -118,43 +243,329 @@ func determineUpdate(updateMessageTs *slacknotify.ResponseTimestamp, reportFinde
 	return nil
 }
 
+// slackOptions builds the options used to create Slack API clients
+func slackOptions(apiUrl string) []slack.Option {
+	var options []slack.Option
+
+	if apiUrl != "" {
+		options = append(options, slack.OptionAPIURL(apiUrl))
+	}
+
+	return options
+}
+
+// tokenFlags are the alternative flags providing the Slack API token
+var tokenFlags = []string{SlackFlagToken, SlackFlagTokenFile, SlackFlagTokenFd}
+
+// buildTokenSource builds the source of the Slack API token from `--token`, `--token-file`
+// or `--token-fd` of `cmd`, whichever is supplied by the most specific source, see
+// `chooseFlag`
+func buildTokenSource(cmd *cobra.Command) (slacknotify.TokenSource, error) {
+	flag, err := chooseFlag(cmd, tokenFlags...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch flag {
+	case SlackFlagToken:
+		return slacknotify.NewStaticTokenSource(stringFlag(cmd, SlackFlagToken)), nil
+
+	case SlackFlagTokenFile:
+		if tokenFile := stringFlag(cmd, SlackFlagTokenFile); tokenFile != "-" {
+			return slacknotify.NewFileTokenSource(tokenFile), nil
+		}
+		return slacknotify.NewReaderTokenSource(cmd.InOrStdin(), "stdin"), nil
+
+	case SlackFlagTokenFd:
+		tokenFd := intFlag(cmd, SlackFlagTokenFd)
+		name := fmt.Sprintf("file descriptor %d", tokenFd)
+		return slacknotify.NewReaderTokenSource(os.NewFile(uintptr(tokenFd), name), name), nil
+
+	default:
+		return nil, fmt.Errorf("one of --%s, --%s or --%s is required", SlackFlagToken, SlackFlagTokenFile, SlackFlagTokenFd)
+	}
+}
+
+// requiredScopes are the OAuth scopes the token needs for every call made by a run configured
+// by the flags: to resolve `channel` when it's a name, to send & look up the reports, and to
+// look up owners by email when `userLookup` is set. Alternatives are separated by `|`, see
+// `slacknotify.tokenValidator.Validate`
+func requiredScopes(channel string, userLookup bool) []string {
+	scopes := []string{"chat:write"}
+
+	if !slacknotify.IsChannelId(channel) {
+		// `conversations.list` & `conversations.info`
+		scopes = append(scopes, slacknotify.ResolveScopes...)
+	}
+
+	// Existing environment replies, continuations & uploads are found in the thread with
+	// `conversations.replies`, and reports in the channel with `conversations.history`
+	lookups := viper.GetBool(SlackFlagUpdateEnvironments) ||
+		viper.GetBool(SlackFlagLookupLastReport) ||
+		viper.GetString(SlackFlagUpdateMessageTs) != "" ||
+		viper.GetInt(SlackFlagPreviousReportDays) > 0 ||
+		(viper.GetInt(SlackFlagTrendDays) > 0 && viper.GetString(SlackFlagHistoryDir) == "")
+	if lookups {
+		scopes = append(scopes, "channels:history|groups:history")
+	}
+
+	if viper.GetInt(SlackFlagUploadThreshold) > 0 {
+		scopes = append(scopes, "files:write")
+	}
+
+	if userLookup {
+		scopes = append(scopes, "users:read.email")
+	}
+
+	return scopes
+}
+
+// buildRetryConfig builds the retry config for Slack API calls from the retry flags
+func buildRetryConfig() slacknotify.RetryConfig {
+	retryConfig := slacknotify.DefaultRetryConfig()
+	retryConfig.MaxAttempts = viper.GetInt(SlackFlagRetryMaxAttempts)
+	retryConfig.MaxElapsed = viper.GetDuration(SlackFlagRetryMaxElapsed)
+
+	return retryConfig
+}
+
+// lookupPreviousReport searches for the most recent report before the current report
+// date, and sets the previous report button target on the provided `reportConfig`. Unless
+// a previous report was already provided, the previous report is also fetched from its
+// metadata to compare with. A failed lookup only hides the button or comparison, so is
+// logged rather than returned
+func lookupPreviousReport(ctx context.Context, reportConfig *report.ReportConfig, reportFinder slacknotify.ReportFinder) {
+	lookbackDays := viper.GetInt(SlackFlagPreviousReportDays)
+	if lookbackDays <= 0 {
+		return
+	}
+
+	previous, err := reportFinder.FindPreviousReport(ctx, reportConfig.ReportDate, lookbackDays)
+	if err != nil {
+		log.Warnf("failed to look up previous report: %v", err)
+		return
+	}
+	if previous == nil {
+		log.Debugf("No previous report found within %d days of %s", lookbackDays, reportConfig.ReportDate)
+		return
+	}
+
+	log.Debugf("Found previous report from %s: %s", previous.Date, previous.Permalink)
+	reportConfig.PreviousReportDate = previous.Date
+	reportConfig.PreviousReportUrl = previous.Permalink
+
+	if reportConfig.PreviousReport == nil {
+		reportConfig.PreviousReport, err = reportFinder.FetchReport(ctx, previous.Date)
+		if err != nil {
+			log.Warnf("failed to fetch previous report to compare with: %v", err)
+		}
+	}
+}
+
+// lookupPastReports collects the reports sent over the last `trendDays` days, so that the
+// summary can show each environment's trend. They're read from the history in `historyDir`
+// when set, and looked up in Slack otherwise. A failed lookup only hides the trends, so is
+// logged rather than returned
+func lookupPastReports(ctx context.Context, reportConfig *report.ReportConfig, reportFinder slacknotify.ReportFinder, historyDir string, trendDays int) {
+	if trendDays <= 0 {
+		return
+	}
+	reportConfig.TrendDays = trendDays
+
+	var err error
+	if historyDir != "" {
+		reportConfig.PastReports, err = readPastReports(historyDir, reportConfig.Kind, reportConfig.ReportDate, trendDays-1)
+	} else {
+		reportConfig.PastReports, err = reportFinder.FindPastReports(ctx, reportConfig.ReportDate, trendDays-1)
+	}
+	if err != nil {
+		log.Warnf("failed to look up past reports for trends: %v", err)
+	}
+}
+
+// readPastReports reads the reports of `kind` recorded in the history in `historyDir` up to
+// `lookbackDays` days before `date`
+func readPastReports(historyDir string, kind string, date string, lookbackDays int) ([]report.PastReport, error) {
+	entries, err := history.NewFileStore(historyDir).Entries(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return history.PastReports(entries, date, lookbackDays), nil
+}
+
+// readPreviousReport reads the previous report to compare with from `filename`, if set
+func readPreviousReport(filename string) (*report.ReportJson, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return report.FromJson(bytes)
+}
+
+// readOwnerRules reads the rules assigning owners to failures from the YAML or JSON
+// `filename`, if set. The file is a list of rules, as in the report's `owners`
+func readOwnerRules(filename string) ([]report.OwnerRule, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := []report.OwnerRule{}
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid owners file: %v", err)
+	}
+
+	if errs := report.ValidateOwnerRules(rules); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid owners file: %v", errors.Join(errs...))
+	}
+
+	return rules, nil
+}
+
+// loadTemplates loads the templates in `templateDir` over the defaults, if set
+func loadTemplates(templateDir string) (*slacknotify.Templates, error) {
+	if templateDir == "" {
+		return slacknotify.DefaultTemplates(), nil
+	}
+
+	return slacknotify.LoadTemplates(templateDir)
+}
+
+// recordHistory records the report of `kind` sent in the history. The report has already been
+// sent, so a failure is logged rather than returned
+func recordHistory(store history.Store, reportDate string, kind string, reportJson report.ReportJson) {
+	if err := store.Record(reportDate, kind, reportJson); err != nil {
+		log.Warnf("failed to record report in history: %v", err)
+		return
+	}
+	log.Debugf("Recorded report for %s in history", reportDate)
+}
+
+// sendProgress tracks which messages have been sent, so that an interrupted run can report
+// how far it got
+type sendProgress struct {
+	summarySent  bool
+	sent         []string
+	environments []report.ReportEnvironment
+}
+
+func (p *sendProgress) log() {
+	if !p.summarySent {
+		log.Warn("Cancelled before the summary report was sent - nothing was sent")
+		return
+	}
+
+	sent := map[string]bool{}
+	for _, env := range p.sent {
+		sent[env] = true
+	}
+
+	var notSent []string
+	for _, env := range p.environments {
+		if !sent[env.Name] {
+			notSent = append(notSent, env.Name)
+		}
+	}
+
+	log.Warnf("Cancelled after sending the summary report and %d/%d environment reports", len(p.sent), len(p.environments))
+	if len(notSent) > 0 {
+		log.Warnf("Environment reports not sent: %s", strings.Join(notSent, ", "))
+	}
+}
+
+// messageOutput describes a message that was created or updated at `ts`, looking up its
+// permalink. A failed lookup only omits the permalink, so is logged rather than returned
+func messageOutput(ctx context.Context, reportFinder slacknotify.ReportFinder, ts slacknotify.ResponseTimestamp, updated bool) MessageOutput {
+	output := MessageOutput{Ts: ts.Ts, Action: ActionCreated}
+	if updated {
+		output.Action = ActionUpdated
+	}
+
+	if !ts.IsEmpty() {
+		permalink, err := reportFinder.FindPermalink(ctx, ts)
+		if err != nil {
+			log.Warnf("failed to look up permalink: %v", err)
+		}
+		output.Permalink = permalink
+	}
+
+	return output
+}
+
 // sendNotifications sends notifications to the specific channels, optionally
 // updating existing messages
-func sendNotifications(slackNotifier slacknotify.SlackNotifier, reportFinder slacknotify.ReportFinder, reportJson report.ReportJson, updateMessageTs *slacknotify.ResponseTimestamp, updateEnvironmentMessages bool) (*slacknotify.ResponseTimestamp, error) {
+func sendNotifications(ctx context.Context, slackNotifier slacknotify.SlackNotifier, reportFinder slacknotify.ReportFinder, reportJson report.ReportJson, updateMessageTs *slacknotify.ResponseTimestamp, updateEnvironmentMessages bool) (_ *Output, err error) {
+	progress := sendProgress{environments: reportJson.Environments}
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			progress.log()
+		}
+	}()
+
+	// Every message is rendered before any is sent, so that a template error doesn't leave a
+	// half-sent report
+	if _, err := slackNotifier.Render(reportJson); err != nil {
+		return nil, fmt.Errorf("failed to render report: %v", err)
+	}
+
 	log.Info("Building summary report")
 
-	parentMessageTs, err := slackNotifier.SendSummaryReport(reportJson, updateMessageTs)
+	// An empty timestamp means there is no existing message to update
+	if updateMessageTs != nil && updateMessageTs.IsEmpty() {
+		updateMessageTs = nil
+	}
+
+	parentMessageTs, err := slackNotifier.SendSummaryReport(ctx, reportJson, updateMessageTs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send summary report: %v", err)
 	}
+	progress.summarySent = true
+
+	output := Output{
+		Summary:      messageOutput(ctx, reportFinder, parentMessageTs, updateMessageTs != nil),
+		Environments: []EnvironmentOutput{},
+	}
 
 	log.Info("Building detailed environment reports")
 
 	for _, env := range reportJson.Environments {
+		var updateEnvironmentReportTs *slacknotify.ResponseTimestamp
+
 		if updateEnvironmentMessages {
 			log.WithField("env", env.Name).Debug("Looking up existing environment")
-			updateEnvironmentReportTs, err := reportFinder.FindEnvironmentReport(env.Name, parentMessageTs)
+			updateEnvironmentReportTs, err = reportFinder.FindEnvironmentReport(ctx, env.Name, parentMessageTs)
 			if err != nil {
-				return &parentMessageTs, err
+				return &output, err
 			}
+		}
 
-			err = slackNotifier.SendEnvironmentReport(parentMessageTs, env, updateEnvironmentReportTs)
-			if err != nil {
-				return &parentMessageTs, fmt.Errorf("failed to send environment report: %v", err)
-			}
-		} else {
-			if env.Status == report.Completed {
-				err := slackNotifier.SendEnvironmentReport(parentMessageTs, env, nil)
-				if err != nil {
-					return &parentMessageTs, fmt.Errorf("failed to send environment report: %v", err)
-				}
-			} else {
-				log.WithField("environment", env.Name).Warnf("Not sending environment report for %s as it has status %s", env.Name, env.Status)
-			}
+		envReportTs, err := slackNotifier.SendEnvironmentReport(ctx, parentMessageTs, env, updateEnvironmentReportTs)
+		if err != nil {
+			return &output, fmt.Errorf("failed to send environment report: %v", err)
 		}
+		progress.sent = append(progress.sent, env.Name)
+
+		output.Environments = append(output.Environments, EnvironmentOutput{
+			Environment:   env.Name,
+			MessageOutput: messageOutput(ctx, reportFinder, envReportTs, updateEnvironmentReportTs != nil),
+		})
 	}
 
-	return nil, nil
+	return &output, nil
 }
 
 var SlackCmd = &cobra.Command{
@@ -166,19 +577,33 @@ var SlackCmd = &cobra.Command{
 
 Example JSON report:
 {
+  "metadata": {
+    "date": "20-09-2023",
+    "job_name": "Jenkins job",
+    "build_number": "1289",
+    "build_url": "https://jenkins/job/bring-up/1289/"
+  },
   "environments": [
     {
-      "name": "abx-xyz-foo-2",
-      "sections": [
+      "name": "dev1",
+      "status": "completed",
+      "namespaces": [
         {
-          "name": "Failed Deployments",
-          "icon": ":package:",
-          "failures": ["foo", "bar", "baz"]
+          "name": "abx-xyz-foo-2",
+          "sections": [
+            {
+              "name": "Failed Deployments",
+              "icon": ":package:",
+              "failures": ["foo", "bar", "baz"]
+            }
+          ]
         }
       ]
     }
   ]
 }
+
+The report is validated before anything is sent, see 'slacker validate'.
 `,
 	Example: `# Automatically look up today's report and attempt to update it, or create a new message if it doesn't exist
 slacker slack-report --channel alerts --token redacted --report-base-url https://my-reports --look-up-last-report report.json
@@ -193,73 +618,222 @@ slacker slack-report --channel alerts --token redacted --report-base-url https:/
 # Collect the report JSON from stdin and create a brand new report
 my-report.sh | slacker slack-report --token redacted --channel alerts --report-base-url https://my-reports -
 
+# Send the report via. an incoming webhook, as top-level messages
+slacker slack-report --webhook-url https://hooks.slack.com/services/redacted --report-base-url https://my-reports report.json
+
+# Read the token from a file, keeping it out of process listings
+slacker slack-report --channel alerts --token-file /run/secrets/slack-token --report-base-url https://my-reports report.json
+
 # Using env vars for config instead of CLI flags
 TOKEN=redacted CHANNEL=alerts REPORT_BASE_URL=https://my-reports slacker slack-report`,
 
 	PreRunE: func(cmd *cobra.Command, args []string) error {
-		return requireFlags(
-			SlackFlagChannel,
-			SlackFlagToken,
-			SlackFlagReportBaseUrl,
+		switch output := viper.GetString(SlackFlagOutput); output {
+		case OutputJson, OutputYaml, OutputNone:
+		default:
+			return fmt.Errorf("unknown output format '%s'", output)
+		}
+
+		if err := checkUploadFlags(viper.GetInt(SlackFlagUploadThreshold), viper.GetInt(SlackFlagUploadTopFailures)); err != nil {
+			return err
+		}
+
+		if flag, _ := chooseFlag(cmd, tokenFlags...); flag == SlackFlagTokenFile && stringFlag(cmd, SlackFlagTokenFile) == "-" && args[0] == "-" {
+			return fmt.Errorf("the token & report can't both be read from stdin")
+		}
+
+		if viper.IsSet(SlackFlagWebhookUrl) {
+			// Webhooks can only post new messages, so anything that relies on the Web API
+			// to look up, update or reply to messages isn't available
+			return errors.Join(
+				requireFlags(SlackFlagReportBaseUrl),
+				rejectFlags(
+					fmt.Sprintf("with '--%s'", SlackFlagWebhookUrl),
+					SlackFlagToken,
+					SlackFlagTokenFile,
+					SlackFlagTokenFd,
+					SlackFlagChannel,
+					SlackFlagUpdateMessageTs,
+					SlackFlagLookupLastReport,
+					SlackFlagPreviousReportDays,
+					SlackFlagUploadThreshold,
+					SlackFlagUploadTopFailures,
+					SlackFlagLookupMaxPages,
+					SlackFlagChannelCache,
+					SlackFlagOwnerEmailDomain,
+				),
+			)
+		}
+
+		return errors.Join(
+			requireFlags(
+				SlackFlagChannel,
+				SlackFlagReportBaseUrl,
+			),
+			requireOneFlag(cmd, tokenFlags...),
 		)
 	},
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			channel            = viper.GetString(SlackFlagChannel)
-			token              = viper.GetString(SlackFlagToken)
 			reportDate         = viper.GetString(SlackFlagReportDate)
 			reportBaseUrl      = viper.GetString(SlackFlagReportBaseUrl)
 			updateEnvironments = viper.GetBool(SlackFlagUpdateEnvironments)
+			webhookUrl         = viper.GetString(SlackFlagWebhookUrl)
+			retryConfig        = buildRetryConfig()
+			options            = slackOptions(viper.GetString(SlackFlagApiUrl))
 			dryRun             = viper.GetBool(SlackFlagDryRun)
 
 			updateMessageTs slacknotify.ResponseTimestamp
 
 			slackNotifier slacknotify.SlackNotifier
 			reportFinder  slacknotify.ReportFinder
+
+			reportConfig = report.ReportConfig{
+				ReportDate:   reportDate,
+				BaseUrl:      reportBaseUrl,
+				LearnMoreUrl: viper.GetString(SlackFlagLearnMoreUrl),
+				Kind:         viper.GetString(SlackFlagReportKind),
+			}
 		)
 
-		if dryRun {
-			slackNotifier = slacknotify.NewDebugNotifier()
-			reportFinder = slacknotify.NewNoOpReportFinder()
-		} else {
-			slackNotifier = slacknotify.NewNotifier(
-				token,
-				channel,
-				report.ReportConfig{
-					ReportDate: reportDate,
-					BaseUrl:    reportBaseUrl,
-				},
-			)
-			reportFinder = slacknotify.NewSlackReportFinder(token, channel)
+		// The timeout also bounds reading the report, as stdin may never be closed
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if timeout := viper.GetDuration(SlackFlagTimeout); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
 		}
 
-		reportJson, err := readJsonReportFromFileOrStdin(cmd, args)
+		reportJson, err := readJsonReportFromFileOrStdin(ctx, cmd, args)
 		if err != nil {
 			return fmt.Errorf("could not read json report: %v", err)
 		}
 
-		if err := determineUpdate(&updateMessageTs, reportFinder); err != nil {
+		reportConfig.PreviousReport, err = readPreviousReport(viper.GetString(SlackFlagPreviousReportFile))
+		if err != nil {
+			return fmt.Errorf("could not read previous json report: %v", err)
+		}
+
+		templates, err := loadTemplates(viper.GetString(SlackFlagTemplateDir))
+		if err != nil {
+			return fmt.Errorf("could not load templates: %v", err)
+		}
+
+		ownerRules, err := readOwnerRules(viper.GetString(SlackFlagOwnersFile))
+		if err != nil {
+			return fmt.Errorf("could not read owners file: %v", err)
+		}
+		reportConfig.OwnerRules = append(slices.Clip(reportJson.Owners), ownerRules...)
+
+		var (
+			owners      = slacknotify.ReportOwners(*reportJson, reportConfig.OwnerRules)
+			emailDomain = viper.GetString(SlackFlagOwnerEmailDomain)
+		)
+
+		var tokenSource slacknotify.TokenSource
+		if dryRun || webhookUrl != "" {
+			reportFinder = slacknotify.NewNoOpReportFinder()
+
+			// Owners can't be looked up without the Web API, so only those given as IDs or
+			// mentions are mentioned
+			reportConfig.Mentions = slacknotify.StaticMentions(owners)
+		} else {
+			if tokenSource, err = buildTokenSource(cmd); err != nil {
+				return err
+			}
+
+			// The token is checked before anything is sent, so that a token missing a scope
+			// doesn't fail the run after the summary report has been posted
+			auth, err := slacknotify.NewTokenValidator(tokenSource, options...).
+				WithRetry(retryConfig).
+				Validate(ctx, requiredScopes(channel, slacknotify.NeedsUserLookup(owners, emailDomain))...)
+			if err != nil {
+				return fmt.Errorf("invalid token: %v", err)
+			}
+			log.Debugf("Authenticated as %s in %s", auth.User, auth.Team)
+
+			// The Web API requires channel IDs, so resolve the channel if a name was given
+			resolver, err := slacknotify.NewChannelResolver(tokenSource, options...)
+			if err != nil {
+				return err
+			}
+			channel, err = resolver.
+				WithRetry(retryConfig).
+				WithCache(viper.GetString(SlackFlagChannelCache), auth.TeamID).
+				Resolve(ctx, channel)
+			if err != nil {
+				return fmt.Errorf("could not resolve channel: %v", err)
+			}
+
+			finder, err := slacknotify.NewSlackReportFinder(tokenSource, channel, options...)
+			if err != nil {
+				return err
+			}
+			reportFinder = finder.
+				WithKind(reportConfig.Kind).
+				WithRetry(retryConfig).
+				WithMaxPages(viper.GetInt(SlackFlagLookupMaxPages))
+
+			mentionResolver, err := slacknotify.NewMentionResolver(tokenSource, options...)
+			if err != nil {
+				return err
+			}
+			reportConfig.Mentions = mentionResolver.
+				WithRetry(retryConfig).
+				WithEmailDomain(emailDomain).
+				Resolve(ctx, owners)
+		}
+
+		if err := determineUpdate(ctx, &updateMessageTs, reportFinder); err != nil {
 			return err
 		}
 
+		lookupPreviousReport(ctx, &reportConfig, reportFinder)
+		lookupPastReports(ctx, &reportConfig, reportFinder, viper.GetString(SlackFlagHistoryDir), viper.GetInt(SlackFlagTrendDays))
+
+		if dryRun {
+			slackNotifier = slacknotify.NewDebugNotifier(reportConfig).
+				WithTemplates(templates).
+				WithFileUpload(viper.GetInt(SlackFlagUploadThreshold), viper.GetInt(SlackFlagUploadTopFailures))
+		} else if webhookUrl != "" {
+			slackNotifier = slacknotify.NewWebhookNotifier(webhookUrl, reportConfig).
+				WithTemplates(templates).
+				WithRetry(retryConfig)
+		} else {
+			notifier, err := slacknotify.NewNotifier(tokenSource, channel, reportConfig, options...)
+			if err != nil {
+				return err
+			}
+			slackNotifier = notifier.
+				WithTemplates(templates).
+				WithRetry(retryConfig).
+				WithMaxPages(viper.GetInt(SlackFlagLookupMaxPages)).
+				WithFinder(reportFinder).
+				WithFileUpload(viper.GetInt(SlackFlagUploadThreshold), viper.GetInt(SlackFlagUploadTopFailures))
+		}
+
 		if dryRun {
 			bytes, _ := json.MarshalIndent(reportJson, "", "  ")
 			log.Debug(string(bytes))
 		}
 
-		summaryReportMessageTs, err := sendNotifications(slackNotifier, reportFinder, *reportJson, &updateMessageTs, updateEnvironments)
+		output, err := sendNotifications(ctx, slackNotifier, reportFinder, *reportJson, &updateMessageTs, updateEnvironments)
 		if err != nil {
 			return err
 		}
 
-		if summaryReportMessageTs != nil {
-			output := Output{ResponseTimestamp: updateMessageTs}
-			if json, err := json.MarshalIndent(output, "", "  "); err == nil {
-				fmt.Println(string(json))
-			}
+		if webhookUrl == "" {
+			output.Channel = channel
 		}
 
-		return nil
+		if historyDir := viper.GetString(SlackFlagHistoryDir); historyDir != "" && !dryRun {
+			recordHistory(history.NewFileStore(historyDir), reportDate, reportConfig.Kind, *reportJson)
+		}
+
+		return writeOutput(cmd.OutOrStdout(), viper.GetString(SlackFlagOutput), *output)
 	},
 }
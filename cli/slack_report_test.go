@@ -0,0 +1,811 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+	"dsab.slacker/report"
+	"dsab.slacker/slacknotify"
+)
+
+const testChannel = "C0123ABCD"
+
+// resetFlags restores every flag to its default, as flag values otherwise persist between
+// executions of the command
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	})
+}
+
+// runSlackReport runs `slacker slack-report` against the fake Slack server, returning the
+// parsed output
+func runSlackReport(t *testing.T, fake *fakeslack.Server, args ...string) (Output, error) {
+	t.Helper()
+	return runSlackReportWithToken(t, fake, []string{"--token", "xoxb-test"}, args...)
+}
+
+// runSlackReportWithToken runs `slacker slack-report` like `runSlackReport`, providing the
+// token with `tokenArgs`
+func runSlackReportWithToken(t *testing.T, fake *fakeslack.Server, tokenArgs []string, args ...string) (Output, error) {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{
+		"slack-report",
+		"--channel", testChannel,
+		"--report-base-url", "https://reports.example.com",
+		"--slack-api-url", fake.APIURL(),
+		"--channel-cache", "",
+		"--retry-max-elapsed", "5s",
+	}, append(tokenArgs, args...)...))
+
+	var output Output
+	if err := RootCmd.Execute(); err != nil {
+		return output, err
+	}
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &output), stdout.String())
+	return output, nil
+}
+
+func TestSlackReportCreatesNewReport(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	// The first post is rate limited, and should be retried
+	fake.RateLimit("chat.postMessage", 1)
+
+	output, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 1)
+	assert.Equal(slacknotify.BRING_UP_HEALTHCHECK, msgs[0].Metadata.EventType)
+	assert.Equal(time.Now().Format(report.DateFormat), msgs[0].Metadata.EventPayload["date"])
+
+	replies := fake.Replies(testChannel, msgs[0].Timestamp)
+	require.Len(t, replies, 3)
+	for i, env := range []string{"dev1", "dev2", "dev3"} {
+		assert.Equal(slacknotify.BRING_UP_HEALTHCHECK_ENVIRONMENT, replies[i].Metadata.EventType)
+		assert.Equal(env, replies[i].Metadata.EventPayload["environment"])
+	}
+
+	assert.Equal(testChannel, output.Channel)
+	assert.Equal(msgs[0].Timestamp, output.Summary.Ts)
+	assert.Equal(ActionCreated, output.Summary.Action)
+	assert.NotEmpty(output.Summary.Permalink)
+	require.Len(t, output.Environments, 3)
+	for i, env := range output.Environments {
+		assert.Equal(replies[i].Timestamp, env.Ts)
+		assert.Equal(ActionCreated, env.Action)
+	}
+}
+
+func TestSlackReportLooksUpAndUpdatesExistingReport(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	first, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	fake.ResetCalls()
+
+	second, err := runSlackReport(t, fake, "--lookup-last-report", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 1)
+	assert.Len(fake.Replies(testChannel, msgs[0].Timestamp), 3)
+	assert.Len(fake.Calls("chat.postMessage"), 0)
+	assert.Len(fake.Calls("chat.update"), 4)
+	// The thread is fetched once, rather than again for each environment
+	assert.Len(fake.Calls("conversations.replies"), 1)
+
+	assert.Equal(first.Summary.Ts, second.Summary.Ts)
+	assert.Equal(ActionUpdated, second.Summary.Action)
+	for i, env := range second.Environments {
+		assert.Equal(first.Environments[i].Ts, env.Ts)
+		assert.Equal(ActionUpdated, env.Action)
+	}
+}
+
+// cursors returns the cursors of the calls to `method`, which are empty for first pages
+func cursors(fake *fakeslack.Server, method string) []string {
+	cursors := []string{}
+	for _, call := range fake.Calls(method) {
+		cursors = append(cursors, call.Values.Get("cursor"))
+	}
+	return cursors
+}
+
+func TestSlackReportLooksUpReportBeyondFirstHistoryPage(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	first, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	// History is 200 messages a page, newest first, so the report is on the second page
+	for i := 0; i < 250; i++ {
+		fake.PostMessage(testChannel, slack.Message{Msg: slack.Msg{Text: fmt.Sprintf("filler %d", i)}})
+	}
+
+	fake.ResetCalls()
+	second, err := runSlackReport(t, fake, "--lookup-last-report", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	assert.Equal(first.Summary.Ts, second.Summary.Ts)
+	assert.Equal(ActionUpdated, second.Summary.Action)
+	assert.Len(fake.Calls("chat.postMessage"), 0)
+	assert.Contains(cursors(fake, "conversations.history"), "200")
+
+	// Without searching the second page, the report isn't found, so a new one is sent
+	fake.ResetCalls()
+	third, err := runSlackReport(t, fake, "--lookup-last-report", "--lookup-max-pages", "1", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	assert.NotEqual(first.Summary.Ts, third.Summary.Ts)
+	assert.Equal(ActionCreated, third.Summary.Action)
+	assert.NotContains(cursors(fake, "conversations.history"), "200")
+}
+
+func TestSlackReportLooksUpEnvironmentsBeyondFirstRepliesPage(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	first, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	// Replies are 200 messages a page, oldest first, so filler replies sorted between the
+	// summary & its environment replies push them onto the second page
+	seconds, _, _ := strings.Cut(first.Summary.Ts, ".")
+	for i := 0; i < 250; i++ {
+		fake.PostMessage(testChannel, slack.Message{Msg: slack.Msg{
+			Text:            fmt.Sprintf("filler %d", i),
+			Timestamp:       fmt.Sprintf("%s.%06d", seconds, 101+i),
+			ThreadTimestamp: first.Summary.Ts,
+		}})
+	}
+
+	fake.ResetCalls()
+	second, err := runSlackReport(t, fake, "--lookup-last-report", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	require.Len(t, second.Environments, 3)
+	for i, env := range second.Environments {
+		assert.Equal(first.Environments[i].Ts, env.Ts)
+		assert.Equal(ActionUpdated, env.Action)
+	}
+	assert.Len(fake.Calls("chat.postMessage"), 0)
+	assert.Contains(cursors(fake, "conversations.replies"), "200")
+
+	// Without searching the second page, the environment replies aren't found, so new ones
+	// are posted
+	fake.ResetCalls()
+	third, err := runSlackReport(t, fake, "--lookup-last-report", "--lookup-max-pages", "1", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	assert.Equal(first.Summary.Ts, third.Summary.Ts)
+	for _, env := range third.Environments {
+		assert.Equal(ActionCreated, env.Action)
+	}
+	assert.Len(fake.Calls("chat.postMessage"), 3)
+	assert.NotContains(cursors(fake, "conversations.replies"), "200")
+}
+
+func TestSlackReportUpdatesExplicitMessageTs(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	first, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	fake.ResetCalls()
+
+	second, err := runSlackReport(t, fake, "--update-message-ts", first.Summary.Ts, "--update-environments=false", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	// Without looking up the existing environment replies, every environment is posted as
+	// a new reply
+	assert.Len(fake.Calls("chat.update"), 1)
+	assert.Len(fake.Calls("chat.postMessage"), 3)
+	assert.Len(fake.Messages(testChannel), 1)
+	assert.Len(fake.Replies(testChannel, first.Summary.Ts), 6)
+
+	assert.Equal(first.Summary.Ts, second.Summary.Ts)
+	assert.Equal(ActionUpdated, second.Summary.Action)
+}
+
+func TestSlackReportRepliesForEnvironmentsThatDidNotComplete(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(reportFile, []byte(`{"environments": [
+		{"name": "dev1", "status": "completed", "namespaces": []},
+		{"name": "dev2", "status": "errored", "error": "Cluster unreachable"}
+	]}`), 0o644))
+
+	output, err := runSlackReport(t, fake, "--update-environments=false", reportFile)
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 1)
+	replies := fake.Replies(testChannel, msgs[0].Timestamp)
+	require.Len(t, replies, 2)
+
+	reply, err := json.Marshal(replies[1].Attachments)
+	require.NoError(t, err)
+	assert.Contains(string(reply), "Errored")
+	assert.Contains(string(reply), "Cluster unreachable")
+
+	require.Len(t, output.Environments, 2)
+	assert.Equal("dev2", output.Environments[1].Environment)
+	assert.Equal(replies[1].Timestamp, output.Environments[1].Ts)
+	assert.Equal(ActionCreated, output.Environments[1].Action)
+}
+
+// uploadedFiles returns the names of the files uploaded into the thread of `ts`
+func uploadedFiles(fake *fakeslack.Server, ts string) []string {
+	names := []string{}
+	for _, reply := range fake.Replies(testChannel, ts) {
+		for _, file := range reply.Files {
+			names = append(names, file.Name)
+		}
+	}
+	return names
+}
+
+func TestSlackReportUploadsFullReports(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	first, err := runSlackReport(t, fake, "--upload-threshold", "5", "--upload-top-failures", "3", "../examples/full.json")
+	require.NoError(t, err)
+
+	// Only dev1 & dev2 have more than 5 failures
+	assert.ElementsMatch([]string{"dev1-report.md", "dev1-report.json", "dev2-report.md", "dev2-report.json"}, uploadedFiles(fake, first.Summary.Ts))
+
+	uploads := fake.Calls("upload")
+	require.Len(t, uploads, 4)
+	assert.Contains(uploads[0].Values.Get("content"), "# Environment: dev1")
+	assert.Contains(uploads[0].Values.Get("content"), "### Failed Pods (3)")
+	assert.Contains(uploads[1].Values.Get("content"), `"name": "dev1"`)
+
+	reply, err := json.Marshal(fake.Replies(testChannel, first.Summary.Ts)[0].Attachments)
+	require.NoError(t, err)
+	assert.Contains(string(reply), "Showing the first 3 of 11 failures")
+
+	// Updating the report replaces the uploads, rather than adding to them
+	fake.ResetCalls()
+	_, err = runSlackReport(t, fake, "--lookup-last-report", "--upload-threshold", "5", "--upload-top-failures", "3", "../examples/full-2.json")
+	require.NoError(t, err)
+
+	assert.Len(fake.Calls("files.delete"), 4)
+	assert.Len(fake.Calls("upload"), 4)
+	assert.Len(fake.Calls("conversations.replies"), 1)
+	assert.ElementsMatch([]string{"dev1-report.md", "dev1-report.json", "dev2-report.md", "dev2-report.json"}, uploadedFiles(fake, first.Summary.Ts))
+
+	// Showing no failures would ignore the threshold
+	_, err = runSlackReport(t, fake, "--upload-threshold", "5", "--upload-top-failures", "0", "../examples/full.json")
+	assert.ErrorContains(err, "Flag '--upload-top-failures' must be at least 1 with '--upload-threshold'")
+}
+
+// runSlackReportWithWebhook runs `slacker slack-report` against the fake Slack server's
+// incoming webhook for `channel`, returning the parsed output
+func runSlackReportWithWebhook(t *testing.T, fake *fakeslack.Server, channel string, args ...string) (Output, error) {
+	t.Helper()
+
+	resetFlags(RootCmd.PersistentFlags())
+	resetFlags(SlackCmd.Flags())
+
+	stdout := &bytes.Buffer{}
+	RootCmd.SetOut(stdout)
+	RootCmd.SetArgs(append([]string{
+		"slack-report",
+		"--webhook-url", fake.WebhookURL(channel),
+		"--retry-max-elapsed", "5s",
+	}, args...))
+
+	var output Output
+	if err := RootCmd.Execute(); err != nil {
+		return output, err
+	}
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &output), stdout.String())
+	return output, nil
+}
+
+func TestSlackReportPostsViaWebhook(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	output, err := runSlackReportWithWebhook(t, fake, "alerts", "--report-base-url", "https://reports.example.com", "../examples/full.json")
+	require.NoError(t, err)
+
+	// Webhooks can't reply in threads, so every message is posted at the top level
+	msgs := fake.Messages("alerts")
+	require.Len(t, msgs, 4)
+	assert.Len(fake.Calls("webhook"), 4)
+	assert.Empty(fake.Calls("chat.postMessage"))
+
+	summary, err := json.Marshal(msgs[0].Blocks)
+	require.NoError(t, err)
+	assert.Contains(string(summary), "Bring-up Healthchecks")
+
+	for i, env := range []string{"dev1", "dev2", "dev3"} {
+		require.NotEmpty(t, msgs[i+1].Attachments)
+		assert.Equal(env, msgs[i+1].Attachments[0].AuthorSubname)
+	}
+
+	assert.Empty(output.Channel)
+	assert.Equal(ActionCreated, output.Summary.Action)
+	require.Len(t, output.Environments, 3)
+	assert.Equal(ActionCreated, output.Environments[0].Action)
+}
+
+func TestSlackReportRejectsWebApiFlagsWithWebhook(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReportWithWebhook(t, fake, "alerts", "../examples/full.json")
+	assert.ErrorContains(err, "Required flag not provided: report-base-url")
+
+	_, err = runSlackReportWithWebhook(t, fake, "alerts",
+		"--report-base-url", "https://reports.example.com",
+		"--channel", "alerts",
+		"--token", "xoxb-test",
+		"--lookup-last-report",
+		"--upload-threshold", "5",
+		"../examples/full.json",
+	)
+	assert.ErrorContains(err, "Flag '--channel' cannot be used with '--webhook-url'")
+	assert.ErrorContains(err, "Flag '--token' cannot be used with '--webhook-url'")
+	assert.ErrorContains(err, "Flag '--lookup-last-report' cannot be used with '--webhook-url'")
+	assert.ErrorContains(err, "Flag '--upload-threshold' cannot be used with '--webhook-url'")
+
+	assert.Empty(fake.Messages("alerts"))
+}
+
+func TestSlackReportUpdatingMissingMessageFails(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	_, err := runSlackReport(t, fake, "--update-message-ts", "1234.5678", "../examples/full.json")
+	assert.ErrorContains(t, err, "message_not_found")
+}
+
+func TestSlackReportDryRunDoesNotCallSlack(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	output, err := runSlackReport(t, fake, "--dry-run", "--lookup-last-report", "../examples/full.json")
+	require.NoError(t, err)
+
+	assert.Empty(fake.Calls(""))
+	assert.Len(output.Environments, 3)
+}
+
+func TestSlackReportRejectsInvalidReport(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	os.WriteFile(reportFile, []byte(`{"environments": [{"name": "dev1", "status": "done"}]}`), 0o644)
+
+	_, err := runSlackReport(t, fake, reportFile)
+	assert.ErrorContains(t, err, "$.environments[0].status: unknown status 'done'")
+	assert.Empty(t, fake.Calls(""))
+}
+
+func TestSlackReportComparesWithPreviousReportMetadata(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(report.DateFormat)
+	_, err := runSlackReport(t, fake, "--report-date", yesterday, "../examples/full.json")
+	require.NoError(t, err)
+
+	_, err = runSlackReport(t, fake, "../examples/full-2.json")
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 2)
+
+	summary, err := json.Marshal(msgs[1].Blocks)
+	require.NoError(t, err)
+	assert.Contains(string(summary), "Yesterday's report")
+	assert.Contains(string(summary), ":repeat: 11 persistent")
+}
+
+// writeLargeReport writes a report with an environment too large to embed in metadata,
+// returning its path
+func writeLargeReport(t *testing.T) string {
+	t.Helper()
+
+	// Random-ish failures, so that they don't compress away
+	failures := []report.Failure{}
+	for i := 0; i < 1000; i++ {
+		failures = append(failures, report.Failure{Id: fmt.Sprintf("pod-%x", sha256.Sum256([]byte(fmt.Sprint(i))))})
+	}
+	data, err := json.Marshal(report.ReportJson{Environments: []report.ReportEnvironment{
+		{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: failures}}},
+		}},
+	}})
+	require.NoError(t, err)
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(reportFile, data, 0o644))
+	return reportFile
+}
+
+func TestSlackReportDoesNotCompareEnvironmentsTooLargeToReadBack(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	reportFile := writeLargeReport(t)
+	_, err := runSlackReport(t, fake, "--report-date", time.Now().AddDate(0, 0, -1).Format(report.DateFormat), reportFile)
+	require.NoError(t, err)
+	_, err = runSlackReport(t, fake, reportFile)
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 2)
+
+	summary, err := json.Marshal(msgs[1].Blocks)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "Yesterday's report")
+	assert.NotContains(t, string(summary), ":new:")
+}
+
+func TestSlackReportShowsTrendFromPreviousReports(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	for _, days := range []int{2, 1} {
+		date := time.Now().AddDate(0, 0, -days).Format(report.DateFormat)
+		_, err := runSlackReport(t, fake, "--report-date", date, "../examples/full.json")
+		require.NoError(t, err)
+	}
+
+	_, err := runSlackReport(t, fake, "../examples/full-2.json")
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 3)
+
+	summary, err := json.Marshal(msgs[2].Blocks)
+	require.NoError(t, err)
+	assert.Contains(string(summary), "*dev1* | Unhealthy - 11 issues (no change vs yesterday, 3 days in a row)")
+	assert.Contains(string(summary), strings.Repeat(":white_large_square:", 4)+strings.Repeat(":large_red_square:", 3))
+}
+
+func TestSlackReportShowsDegradedDaysInTrend(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(reportFile, []byte(`{"environments": [{"name": "dev1", "status": "completed", "namespaces": [
+		{"name": "ns1", "sections": [{"name": "Failed Pods", "failures": [{"id": "foo", "severity": "warning"}]}]}
+	]}]}`), 0o644))
+
+	_, err := runSlackReport(t, fake, "--report-date", time.Now().AddDate(0, 0, -1).Format(report.DateFormat), reportFile)
+	require.NoError(t, err)
+	_, err = runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 2)
+
+	summary, err := json.Marshal(msgs[1].Blocks)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), strings.Repeat(":white_large_square:", 5)+":large_orange_square::large_red_square:")
+}
+
+func TestSlackReportUsesTemplateDir(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	templateDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "line.tmpl"),
+		[]byte(`{{ define "environmentLine" }}{{ .Environment.Name }} is {{ .Environment.Status }}{{ end }}`), 0o644))
+
+	_, err := runSlackReport(t, fake, "--template-dir", templateDir, "../examples/full.json")
+	require.NoError(t, err)
+
+	summary, err := json.Marshal(fake.Messages(testChannel)[0].Blocks)
+	require.NoError(t, err)
+	assert.Contains(string(summary), "dev3 is pending")
+	assert.Contains(string(summary), "Bring-up Healthchecks")
+
+	_, err = runSlackReport(t, fake, "--template-dir", t.TempDir(), "../examples/full.json")
+	assert.ErrorContains(err, "could not load templates: no templates found")
+}
+
+func TestSlackReportRendersEveryMessageBeforeSending(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	// Only environment replies show sections, so the summary would render fine
+	templateDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "section.tmpl"),
+		[]byte(`{{ define "sectionHeader" }}{{ .Missing }}{{ end }}`), 0o644))
+
+	_, err := runSlackReport(t, fake, "--template-dir", templateDir, "../examples/full.json")
+	assert.ErrorContains(t, err, "failed to render report")
+	assert.Empty(t, fake.Calls("chat.postMessage"))
+}
+
+func TestSlackReportKindsDontFindEachOther(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	bringUp, err := runSlackReport(t, fake, "../examples/full.json")
+	require.NoError(t, err)
+
+	smoke, err := runSlackReport(t, fake, "--report-kind", "smoke", "--lookup-last-report", "../examples/full.json")
+	require.NoError(t, err)
+	assert.Equal(ActionCreated, smoke.Summary.Action)
+	assert.NotEqual(bringUp.Summary.Ts, smoke.Summary.Ts)
+
+	updated, err := runSlackReport(t, fake, "--lookup-last-report", "../examples/full-2.json")
+	require.NoError(t, err)
+	assert.Equal(ActionUpdated, updated.Summary.Action)
+	assert.Equal(bringUp.Summary.Ts, updated.Summary.Ts)
+
+	updated, err = runSlackReport(t, fake, "--report-kind", "smoke", "--lookup-last-report", "../examples/full-2.json")
+	require.NoError(t, err)
+	assert.Equal(smoke.Summary.Ts, updated.Summary.Ts)
+
+	assert.Len(fake.Messages(testChannel), 2)
+}
+
+func TestSlackReportReadsTokenFromFileOrStdin(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	fake.SetToken("xoxb-secret")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("xoxb-secret\n"), 0o600))
+
+	_, err := runSlackReportWithToken(t, fake, []string{"--token-file", tokenFile}, "../examples/full.json")
+	require.NoError(t, err)
+
+	RootCmd.SetIn(strings.NewReader("xoxb-secret\n"))
+	t.Cleanup(func() { RootCmd.SetIn(nil) })
+
+	_, err = runSlackReportWithToken(t, fake, []string{"--token-file", "-"}, "../examples/full.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages(testChannel), 2)
+
+	_, err = runSlackReportWithToken(t, fake, []string{"--token-file", "-"}, "-")
+	assert.ErrorContains(err, "the token & report can't both be read from stdin")
+
+	_, err = runSlackReportWithToken(t, fake, []string{"--token", "xoxb-secret", "--token-file", tokenFile}, "../examples/full.json")
+	assert.ErrorContains(err, "Flags '--token', '--token-file' cannot be used together")
+
+	_, err = runSlackReportWithToken(t, fake, nil, "../examples/full.json")
+	assert.ErrorContains(err, "Required flag not provided: one of token, token-file, token-fd")
+}
+
+func TestSlackReportTimesOutReadingStdin(t *testing.T) {
+	fake := fakeslack.New(t)
+
+	// Stdin is never closed
+	stdin, stdinWriter := io.Pipe()
+	RootCmd.SetIn(stdin)
+	t.Cleanup(func() {
+		RootCmd.SetIn(nil)
+		stdinWriter.Close()
+	})
+
+	_, err := runSlackReport(t, fake, "--timeout", "50ms", "-")
+	assert.ErrorContains(t, err, "could not read json report: context deadline exceeded")
+	assert.Empty(t, fake.Calls(""))
+}
+
+func TestSlackReportValidatesToken(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+
+	fake.SetToken("xoxb-other")
+	_, err := runSlackReport(t, fake, "../examples/full.json")
+	assert.ErrorContains(err, "invalid token: token was rejected by Slack: invalid_auth")
+
+	fake.SetToken("")
+	fake.SetScopes("chat:write", "channels:read")
+	_, err = runSlackReport(t, fake, "--upload-threshold", "5", "../examples/full.json")
+	assert.ErrorContains(err, "token is missing the scopes 'channels:history' or 'groups:history', 'files:write' (granted: channels:read, chat:write)")
+	assert.Empty(fake.Calls("chat.postMessage"))
+
+	// Existing environment replies are looked up in the thread, even without other lookups
+	fake.SetScopes("chat:write")
+	_, err = runSlackReport(t, fake, "--previous-report-lookback-days", "0", "--trend-days", "0", "../examples/full.json")
+	assert.ErrorContains(err, "token is missing the scopes 'channels:history' or 'groups:history' (granted: chat:write)")
+	assert.Empty(fake.Calls("chat.postMessage"))
+
+	// Channel names are resolved by listing both public & private channels
+	fake.SetScopes("chat:write", "channels:history", "channels:read")
+	_, err = runSlackReport(t, fake, "--channel", "alerts", "../examples/full.json")
+	assert.ErrorContains(err, "token is missing the scopes 'groups:read' (granted: channels:history, channels:read, chat:write)")
+
+	// Private channels only need the `groups:` scopes
+	fake.SetScopes("chat:write", "groups:history", "files:write")
+	_, err = runSlackReport(t, fake, "--upload-threshold", "5", "../examples/full.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages(testChannel), 1)
+
+	fake.SetScopes("chat:write")
+	_, err = runSlackReport(t, fake, "--update-environments=false", "--previous-report-lookback-days", "0", "--trend-days", "0", "../examples/full.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages(testChannel), 2)
+}
+
+func TestSlackReportReadsTokenFromFd(t *testing.T) {
+	fake := fakeslack.New(t)
+	fake.SetToken("xoxb-secret")
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	t.Cleanup(func() { reader.Close() })
+
+	_, err = writer.WriteString("xoxb-secret\n")
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	_, err = runSlackReportWithToken(t, fake, []string{"--token-fd", strconv.Itoa(int(reader.Fd()))}, "../examples/full.json")
+	require.NoError(t, err)
+	assert.Len(t, fake.Messages(testChannel), 1)
+}
+
+func TestSlackReportMentionsOwners(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	fake.AddUser(fakeslack.User{ID: "U0ALICE123", Email: "alice@example.com"})
+
+	ownersFile := filepath.Join(t.TempDir(), "owners.yaml")
+	writeOwners := func(content string) {
+		require.NoError(t, os.WriteFile(ownersFile, []byte(content), 0o644))
+	}
+
+	writeOwners(`
+- namespace: abx-xyz-foo-2
+  owners: ["@alice"]
+- section: Failed Pods
+  owners: [S0PODS1234]
+`)
+
+	fake.SetScopes("chat:write", "channels:history")
+	_, err := runSlackReport(t, fake, "--owners-file", ownersFile, "--owner-email-domain", "example.com", "../examples/full.json")
+	assert.ErrorContains(err, "token is missing the scopes 'users:read.email'")
+
+	fake.SetScopes()
+	_, err = runSlackReport(t, fake, "--owners-file", ownersFile, "--owner-email-domain", "example.com", "../examples/full.json")
+	require.NoError(t, err)
+	assert.Len(fake.Calls("users.lookupByEmail"), 1)
+
+	msgs := fake.Messages(testChannel)
+	require.Len(t, msgs, 1)
+	replies := fake.Replies(testChannel, msgs[0].Timestamp)
+	require.Len(t, replies, 3)
+	assert.Equal("cc <!subteam^S0PODS1234> <@U0ALICE123>", replies[0].Text)
+
+	// An unchanged report doesn't ping anyone again
+	fake.ResetCalls()
+	_, err = runSlackReport(t, fake, "--owners-file", ownersFile, "--owner-email-domain", "example.com", "--lookup-last-report", "../examples/full.json")
+	require.NoError(t, err)
+	assert.Empty(fake.Calls("chat.postMessage"))
+
+	// Only the owners added since are pinged, as edits don't notify anyone
+	writeOwners(`
+- namespace: abx-xyz-foo-2
+  owners: ["@alice"]
+- section: Failed Pods
+  owners: [S0PODS1234]
+- section: Failed Deployments
+  owners: [U0BOB12345]
+`)
+
+	fake.ResetCalls()
+	_, err = runSlackReport(t, fake, "--owners-file", ownersFile, "--owner-email-domain", "example.com", "--lookup-last-report", "../examples/full.json")
+	require.NoError(t, err)
+
+	replies = fake.Replies(testChannel, msgs[0].Timestamp)
+	require.Len(t, replies, 5)
+	assert.Equal("cc <!subteam^S0PODS1234> <@U0BOB12345> <@U0ALICE123>", replies[0].Text)
+	assert.Equal("dev1: cc <@U0BOB12345>", replies[3].Text)
+	assert.Equal("dev2: cc <@U0BOB12345>", replies[4].Text)
+	assert.Empty(replies[3].Metadata.EventType)
+
+	writeOwners(`- namespace: "["`)
+	_, err = runSlackReport(t, fake, "--owners-file", ownersFile, "../examples/full.json")
+	assert.ErrorContains(err, "could not read owners file: invalid owners file: $[0].namespace: invalid pattern '['")
+}
+
+func TestSlackReportResolvesChannelNames(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	cache := filepath.Join(t.TempDir(), "channels.json")
+
+	// More channels than are listed in one page of `conversations.list`
+	for i := 0; i < 1000; i++ {
+		fake.AddChannel(fakeslack.Channel{ID: fmt.Sprintf("C%08d", i), Name: fmt.Sprintf("filler-%d", i), IsMember: true})
+	}
+	fake.AddChannel(fakeslack.Channel{ID: "C0ALERTS01", Name: "alerts", IsMember: true})
+	fake.AddChannel(fakeslack.Channel{ID: "C0LURKING1", Name: "lurking"})
+
+	_, err := runSlackReport(t, fake, "--channel", "#Alerts", "--channel-cache", cache, "../examples/minimal.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages("C0ALERTS01"), 1)
+	assert.Len(fake.Calls("conversations.list"), 2)
+
+	// Cached channels are only checked, rather than listing every channel again
+	fake.ResetCalls()
+	_, err = runSlackReport(t, fake, "--channel", "alerts", "--channel-cache", cache, "../examples/minimal.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages("C0ALERTS01"), 2)
+	assert.Empty(fake.Calls("conversations.list"))
+	assert.Len(fake.Calls("conversations.info"), 1)
+
+	// A channel that has since been deleted & recreated is looked up again
+	fake.RemoveChannel("C0ALERTS01")
+	fake.AddChannel(fakeslack.Channel{ID: "C0ALERTS02", Name: "alerts", IsMember: true})
+	_, err = runSlackReport(t, fake, "--channel", "alerts", "--channel-cache", cache, "../examples/minimal.json")
+	require.NoError(t, err)
+	assert.Len(fake.Messages("C0ALERTS02"), 1)
+
+	// Channels of the same name in another workspace are cached separately
+	other := fakeslack.New(t)
+	other.SetTeam("T0OTHER01")
+	other.AddChannel(fakeslack.Channel{ID: "C0ELSEWHR1", Name: "alerts", IsMember: true})
+	_, err = runSlackReport(t, other, "--channel", "alerts", "--channel-cache", cache, "../examples/minimal.json")
+	require.NoError(t, err)
+	assert.Len(other.Messages("C0ELSEWHR1"), 1)
+
+	cached, err := os.ReadFile(cache)
+	require.NoError(t, err)
+	assert.JSONEq(`{"T0000FAKE": {"alerts": "C0ALERTS02"}, "T0OTHER01": {"alerts": "C0ELSEWHR1"}}`, string(cached))
+
+	_, err = runSlackReport(t, fake, "--channel", "lurking", "--channel-cache", cache, "../examples/minimal.json")
+	assert.ErrorContains(err, "not a member of channel #lurking (C0LURKING1)")
+
+	_, err = runSlackReport(t, fake, "--channel", "missing", "--channel-cache", cache, "../examples/minimal.json")
+	assert.ErrorContains(err, "channel #missing not found")
+}
+
+func TestSlackReportEvictsCachedChannelWhenLeft(t *testing.T) {
+	fake := fakeslack.New(t)
+	cache := filepath.Join(t.TempDir(), "channels.json")
+	fake.AddChannel(fakeslack.Channel{ID: "C0ALERTS01", Name: "alerts", IsMember: true})
+
+	_, err := runSlackReport(t, fake, "--channel", "alerts", "--channel-cache", cache, "../examples/minimal.json")
+	require.NoError(t, err)
+
+	// The app has since been removed from the channel, which a cache hit mustn't hide
+	fake.RemoveChannel("C0ALERTS01")
+	fake.AddChannel(fakeslack.Channel{ID: "C0ALERTS01", Name: "alerts"})
+
+	_, err = runSlackReport(t, fake, "--channel", "alerts", "--channel-cache", cache, "../examples/minimal.json")
+	assert.ErrorContains(t, err, "not a member of channel #alerts (C0ALERTS01)")
+
+	cached, err := os.ReadFile(cache)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"T0000FAKE": {}}`, string(cached))
+}
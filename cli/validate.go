@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"dsab.slacker/report"
+)
+
+var ValidateCmd = &cobra.Command{
+	Use:   "validate [FILE]",
+	Short: "Validates a report JSON document without sending it",
+
+	Args: cobra.ExactArgs(1),
+	Long: `Validates a report JSON, either from file or from stdin.
+
+Every problem found is printed with its JSON path, eg.
+
+  $.environments[1].status: unknown status 'done', expected one of 'pending', 'running', 'completed', 'errored', 'skipped'
+
+The same validation is always run by 'slacker slack-report' before sending. The JSON
+Schema of the report format is printed by 'slacker schema'.`,
+	Example: `# Validate a report file
+slacker validate report.json
+
+# Validate a report from stdin
+my-report.sh | slacker validate -`,
+
+	// An invalid report isn't a usage error
+	SilenceUsage: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bytes, err := readFileOrStdin(cmd.Context(), cmd, args)
+		if err != nil {
+			return fmt.Errorf("could not read json report: %v", err)
+		}
+
+		_, errs := report.Validate(bytes)
+		for _, err := range errs {
+			fmt.Fprintln(cmd.OutOrStdout(), err)
+		}
+
+		if len(errs) > 0 {
+			return fmt.Errorf("report is invalid: %d problem(s) found", len(errs))
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), "Report is valid")
+		return nil
+	},
+}
+
+var SchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the report format",
+
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bytes, err := report.MarshalJsonSchema()
+		if err != nil {
+			return err
+		}
+
+		_, err = cmd.OutOrStdout().Write(bytes)
+		return err
+	},
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"dsab.slacker/report"
+)
+
+// historyFilename is the JSON-lines file within the history directory that reports are
+// appended to
+const historyFilename = "reports.jsonl"
+
+// Entry is a report recorded in the history. Entries without a kind are of the default kind
+type Entry struct {
+	Date       string            `json:"date"`
+	Kind       string            `json:"kind,omitempty"`
+	RecordedAt time.Time         `json:"recorded_at"`
+	Report     report.ReportJson `json:"report"`
+}
+
+// Store records each report sent, so that trends can be queried later. Different kinds of
+// report are kept apart, so can share a store
+type Store interface {
+	Record(date string, kind string, reportJson report.ReportJson) error
+	Entries(kind string) ([]Entry, error)
+}
+
+// Interface assertions
+var (
+	_ Store = (*fileStore)(nil)
+)
+
+//-----------------------------------------------------------------------------------------
+
+// fileStore appends reports to a JSON-lines file in a directory. Reports re-sent for the
+// same date & kind are appended again, and the most recently recorded report wins
+type fileStore struct {
+	dir string
+	now func() time.Time
+}
+
+func NewFileStore(dir string) *fileStore {
+	return &fileStore{
+		dir: dir,
+		now: time.Now,
+	}
+}
+
+func (s *fileStore) path() string {
+	return filepath.Join(s.dir, historyFilename)
+}
+
+// Record appends the report of `kind` sent for `date` to the history
+func (s *fileStore) Record(date string, kind string, reportJson report.ReportJson) error {
+	if _, err := time.Parse(report.DateFormat, date); err != nil {
+		return fmt.Errorf("invalid report date '%s': %v", date, err)
+	}
+
+	line, err := json.Marshal(Entry{
+		Date:       date,
+		Kind:       kind,
+		RecordedAt: s.now().UTC(),
+		Report:     reportJson,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// Entries returns the most recently recorded report of `kind` for each date, oldest date
+// first. A missing history is empty
+func (s *fileStore) Entries(kind string) ([]Entry, error) {
+	file, err := os.Open(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	byDate := map[string]Entry{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid history entry at %s:%d: %v", s.path(), lineNumber, err)
+		}
+
+		if entry.Kind != kind {
+			continue
+		}
+
+		// Entries are appended in the order they're recorded
+		byDate[entry.Date] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sortEntries(byDate), nil
+}
+
+// sortEntries orders entries by their report date, oldest first
+func sortEntries(byDate map[string]Entry) []Entry {
+	entries := make([]Entry, 0, len(byDate))
+	for _, entry := range byDate {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return dateTime(entries[i].Date).Before(dateTime(entries[j].Date))
+	})
+
+	return entries
+}
@@ -0,0 +1,87 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/report"
+)
+
+func TestFileStoreRecordsLatestReportPerDate(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewFileStore(filepath.Join(t.TempDir(), "history"))
+	store.now = func() time.Time { return time.Date(2023, 9, 21, 9, 0, 0, 0, time.UTC) }
+
+	first := report.ReportJson{Environments: []report.ReportEnvironment{{Name: "dev1", Status: report.Pending}}}
+	resent := report.ReportJson{Environments: []report.ReportEnvironment{{Name: "dev1", Status: report.Completed}}}
+	earlier := report.ReportJson{Environments: []report.ReportEnvironment{{Name: "dev2", Status: report.Completed}}}
+
+	require.NoError(t, store.Record("21-09-2023", "", first))
+	require.NoError(t, store.Record("21-09-2023", "", resent))
+	require.NoError(t, store.Record("20-09-2023", "", earlier))
+
+	entries, err := store.Entries("")
+	require.NoError(t, err)
+
+	require.Len(t, entries, 2)
+	assert.Equal("20-09-2023", entries[0].Date)
+	assert.Equal(earlier, entries[0].Report)
+	assert.Equal("21-09-2023", entries[1].Date)
+	assert.Equal(resent, entries[1].Report)
+	assert.Equal(store.now(), entries[1].RecordedAt)
+}
+
+func TestFileStoreMissingHistoryIsEmpty(t *testing.T) {
+	entries, err := NewFileStore(t.TempDir()).Entries("")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFileStoreRejectsInvalidDate(t *testing.T) {
+	err := NewFileStore(t.TempDir()).Record("2023-09-21", "", report.ReportJson{})
+	assert.ErrorContains(t, err, "invalid report date '2023-09-21'")
+}
+
+func TestFileStoreReportsInvalidLine(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+
+	require.NoError(t, store.Record("21-09-2023", "", report.ReportJson{}))
+
+	file, err := os.OpenFile(store.path(), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString("{not json\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	_, err = store.Entries("")
+	assert.ErrorContains(t, err, "reports.jsonl:2")
+}
+
+func TestFileStoreKeepsKindsApart(t *testing.T) {
+	assert := assert.New(t)
+	store := NewFileStore(t.TempDir())
+
+	nightly := report.ReportJson{Environments: []report.ReportEnvironment{{Name: "dev1", Status: report.Completed}}}
+	smoke := report.ReportJson{Environments: []report.ReportEnvironment{{Name: "dev2", Status: report.Completed}}}
+
+	require.NoError(t, store.Record("21-09-2023", "", nightly))
+	require.NoError(t, store.Record("21-09-2023", "smoke", smoke))
+
+	entries, err := store.Entries("")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(nightly, entries[0].Report)
+
+	entries, err = store.Entries("smoke")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal("smoke", entries[0].Kind)
+	assert.Equal(smoke, entries[0].Report)
+}
@@ -0,0 +1,299 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"dsab.slacker/report"
+)
+
+// Run summarises a recorded report
+type Run struct {
+	Date         string    `json:"date"`
+	RecordedAt   time.Time `json:"recorded_at"`
+	Environments int       `json:"environments"`
+	Unhealthy    int       `json:"unhealthy"`
+	Errors       int       `json:"errors"`
+}
+
+// Runs summarises each recorded report
+func Runs(entries []Entry) []Run {
+	runs := []Run{}
+
+	for _, entry := range entries {
+		run := Run{
+			Date:         entry.Date,
+			RecordedAt:   entry.RecordedAt,
+			Environments: len(entry.Report.Environments),
+		}
+
+		for _, env := range entry.Report.Environments {
+			run.Errors += env.Errors()
+			if env.Status == report.Completed && !env.IsHealthy() {
+				run.Unhealthy++
+			}
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs
+}
+
+// DailyErrors is the number of errors in each completed environment on a date
+type DailyErrors struct {
+	Date   string         `json:"date"`
+	Errors map[string]int `json:"errors"`
+}
+
+// ErrorCounts lists the errors in each completed environment over time. Environments that
+// hadn't completed are left out
+func ErrorCounts(entries []Entry) []DailyErrors {
+	counts := []DailyErrors{}
+
+	for _, entry := range entries {
+		daily := DailyErrors{Date: entry.Date, Errors: map[string]int{}}
+		for _, env := range entry.Report.Environments {
+			if env.Status == report.Completed {
+				daily.Errors[env.Name] = env.Errors()
+			}
+		}
+		counts = append(counts, daily)
+	}
+
+	return counts
+}
+
+// Environments lists the names of every environment in the history, sorted by name
+func Environments(entries []Entry) []string {
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		for _, env := range entry.Report.Environments {
+			seen[env.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// healthSample is the health of an environment on a date
+type healthSample struct {
+	date   string
+	health report.Health
+}
+
+// environmentHealth lists the health of each environment over time, oldest first. Only
+// completed environments have a known health, so others are skipped
+func environmentHealth(entries []Entry) map[string][]healthSample {
+	health := map[string][]healthSample{}
+
+	for _, entry := range entries {
+		for _, env := range entry.Report.Environments {
+			if env.Status != report.Completed {
+				continue
+			}
+			health[env.Name] = append(health[env.Name], healthSample{date: entry.Date, health: env.Health()})
+		}
+	}
+
+	return health
+}
+
+// Streak is the current run of consecutive reports of an environment with the same health
+type Streak struct {
+	Environment string        `json:"environment"`
+	Health      report.Health `json:"health"`
+	Runs        int           `json:"runs"`
+	Since       string        `json:"since"`
+}
+
+// Streaks returns the current streak of each environment, sorted by environment name
+func Streaks(entries []Entry) []Streak {
+	streaks := []Streak{}
+
+	for env, samples := range environmentHealth(entries) {
+		latest := samples[len(samples)-1]
+		streak := Streak{Environment: env, Health: latest.health}
+
+		for i := len(samples) - 1; i >= 0 && samples[i].health == latest.health; i-- {
+			streak.Runs++
+			streak.Since = samples[i].date
+		}
+
+		streaks = append(streaks, streak)
+	}
+
+	sort.Slice(streaks, func(i, j int) bool {
+		return streaks[i].Environment < streaks[j].Environment
+	})
+
+	return streaks
+}
+
+// Recovery describes how quickly an environment recovers once it stops being healthy
+type Recovery struct {
+	Environment string `json:"environment"`
+	// Incidents is the number of times the environment stopped being healthy
+	Incidents int `json:"incidents"`
+	// Recovered is the number of incidents that have ended, which the mean time to recovery
+	// is calculated from
+	Recovered          int           `json:"recovered"`
+	MeanTimeToRecovery time.Duration `json:"mean_time_to_recovery"`
+	// Ongoing is set when the environment currently isn't healthy
+	Ongoing bool `json:"ongoing"`
+}
+
+// Recoveries calculates the mean time to recovery of each environment, from the date of the
+// first degraded or unhealthy report of each incident to the date of the next healthy
+// report. Sorted by environment name
+func Recoveries(entries []Entry) []Recovery {
+	recoveries := []Recovery{}
+
+	for env, samples := range environmentHealth(entries) {
+		var (
+			recovery = Recovery{Environment: env}
+			total    time.Duration
+			start    string
+		)
+
+		for _, sample := range samples {
+			switch {
+			case sample.health != report.Healthy && start == "":
+				start = sample.date
+				recovery.Incidents++
+			case sample.health == report.Healthy && start != "":
+				total += dateTime(sample.date).Sub(dateTime(start))
+				recovery.Recovered++
+				start = ""
+			}
+		}
+
+		recovery.Ongoing = start != ""
+		if recovery.Recovered > 0 {
+			recovery.MeanTimeToRecovery = total / time.Duration(recovery.Recovered)
+		}
+
+		recoveries = append(recoveries, recovery)
+	}
+
+	sort.Slice(recoveries, func(i, j int) bool {
+		return recoveries[i].Environment < recoveries[j].Environment
+	})
+
+	return recoveries
+}
+
+// NamespaceFailures counts how often a namespace has failed
+type NamespaceFailures struct {
+	Environment string `json:"environment"`
+	Namespace   string `json:"namespace"`
+	// Runs is the number of reports in which the namespace had failures
+	Runs     int `json:"runs"`
+	Failures int `json:"failures"`
+}
+
+// TopNamespaces ranks namespaces by the number of reports they failed in, then by their
+// total failures
+func TopNamespaces(entries []Entry) []NamespaceFailures {
+	type key struct{ env, ns string }
+	counts := map[key]*NamespaceFailures{}
+
+	for _, entry := range entries {
+		for _, env := range entry.Report.Environments {
+			for _, ns := range env.Namespaces {
+				failures := 0
+				for _, section := range ns.Sections {
+					failures += len(section.Failures)
+				}
+				if failures == 0 {
+					continue
+				}
+
+				k := key{env.Name, ns.Name}
+				if counts[k] == nil {
+					counts[k] = &NamespaceFailures{Environment: env.Name, Namespace: ns.Name}
+				}
+				counts[k].Runs++
+				counts[k].Failures += failures
+			}
+		}
+	}
+
+	ranked := make([]NamespaceFailures, 0, len(counts))
+	for _, count := range counts {
+		ranked = append(ranked, *count)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		if a.Environment != b.Environment {
+			return a.Environment < b.Environment
+		}
+		return a.Namespace < b.Namespace
+	})
+
+	return ranked
+}
+
+// dateTime parses a report date, which are validated when recorded
+func dateTime(date string) time.Time {
+	t, _ := time.Parse(report.DateFormat, date)
+	return t
+}
+
+// Window keeps the entries within `days` days of the latest entry, or every entry if `days`
+// isn't positive
+func Window(entries []Entry, days int) []Entry {
+	if days <= 0 || len(entries) == 0 {
+		return entries
+	}
+
+	cutoff := dateTime(entries[len(entries)-1].Date).AddDate(0, 0, -days)
+
+	for i, entry := range entries {
+		if dateTime(entry.Date).After(cutoff) {
+			return entries[i:]
+		}
+	}
+
+	return []Entry{}
+}
+
+// PastReports lists the errors & health of each completed environment of the reports
+// recorded up to `lookbackDays` days before `date`, oldest first
+func PastReports(entries []Entry, date string, lookbackDays int) []report.PastReport {
+	past := []report.PastReport{}
+
+	reportDate := dateTime(date)
+	cutoff := reportDate.AddDate(0, 0, -lookbackDays)
+
+	for _, entry := range entries {
+		pastDate := dateTime(entry.Date)
+		if pastDate.Before(cutoff) || !pastDate.Before(reportDate) {
+			continue
+		}
+
+		pastReport := report.PastReport{Date: entry.Date, Errors: map[string]int{}, Health: map[string]report.Health{}}
+		for _, env := range entry.Report.Environments {
+			if env.Status == report.Completed {
+				pastReport.Errors[env.Name] = env.Errors()
+				pastReport.Health[env.Name] = env.Health()
+			}
+		}
+		past = append(past, pastReport)
+	}
+
+	return past
+}
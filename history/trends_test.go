@@ -0,0 +1,112 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+// env builds a completed environment with `errors` failures in a single namespace
+func env(name string, errors int) report.ReportEnvironment {
+	failures := make([]report.Failure, errors)
+	for i := range failures {
+		failures[i] = report.Failure{Id: fmt.Sprintf("failure-%d", i)}
+	}
+
+	return report.ReportEnvironment{
+		Name:   name,
+		Status: report.Completed,
+		Namespaces: []report.Namespace{
+			{Name: name + "-ns", Sections: []report.Section{{Name: "Failed Pods", Failures: failures}}},
+		},
+	}
+}
+
+func entry(date string, envs ...report.ReportEnvironment) Entry {
+	return Entry{Date: date, Report: report.ReportJson{Environments: envs}}
+}
+
+// testEntries is a week in which dev1 is broken twice, recovering after 2 days then staying
+// broken, and dev2 is broken until it's last reported
+var testEntries = []Entry{
+	entry("01-09-2023", env("dev1", 0), env("dev2", 1)),
+	entry("02-09-2023", env("dev1", 2), env("dev2", 3)),
+	entry("03-09-2023", env("dev1", 1), report.ReportEnvironment{Name: "dev2", Status: report.Pending}),
+	entry("04-09-2023", env("dev1", 0), env("dev2", 2)),
+	entry("06-09-2023", env("dev1", 4), env("dev2", 0)),
+	entry("07-09-2023", env("dev1", 3)),
+}
+
+func TestRuns(t *testing.T) {
+	assert.Equal(t, []Run{
+		{Date: "01-09-2023", Environments: 2, Unhealthy: 1, Errors: 1},
+		{Date: "02-09-2023", Environments: 2, Unhealthy: 2, Errors: 5},
+		{Date: "03-09-2023", Environments: 2, Unhealthy: 1, Errors: 1},
+		{Date: "04-09-2023", Environments: 2, Unhealthy: 1, Errors: 2},
+		{Date: "06-09-2023", Environments: 2, Unhealthy: 1, Errors: 4},
+		{Date: "07-09-2023", Environments: 1, Unhealthy: 1, Errors: 3},
+	}, Runs(testEntries))
+}
+
+func TestErrorCountsSkipsIncompleteEnvironments(t *testing.T) {
+	counts := ErrorCounts(testEntries)
+
+	assert.Equal(t, DailyErrors{Date: "03-09-2023", Errors: map[string]int{"dev1": 1}}, counts[2])
+	assert.Equal(t, []string{"dev1", "dev2"}, Environments(testEntries))
+}
+
+func TestStreaks(t *testing.T) {
+	assert.Equal(t, []Streak{
+		{Environment: "dev1", Health: report.Unhealthy, Runs: 2, Since: "06-09-2023"},
+		{Environment: "dev2", Health: report.Healthy, Runs: 1, Since: "06-09-2023"},
+	}, Streaks(testEntries))
+}
+
+func TestStreaksAndRecoveriesDontCountDegradedReportsAsHealthy(t *testing.T) {
+	assert := assert.New(t)
+
+	degraded := env("dev1", 0)
+	degraded.Namespaces[0].Sections[0].Failures = []report.Failure{{Id: "foo", Severity: report.SeverityWarning}}
+
+	entries := []Entry{
+		entry("01-09-2023", env("dev1", 0)),
+		entry("02-09-2023", degraded),
+		entry("04-09-2023", env("dev1", 0)),
+		entry("05-09-2023", env("dev1", 1)),
+		entry("06-09-2023", degraded),
+		entry("07-09-2023", degraded),
+	}
+
+	assert.Equal([]Streak{
+		{Environment: "dev1", Health: report.Degraded, Runs: 2, Since: "06-09-2023"},
+	}, Streaks(entries))
+	assert.Equal([]Recovery{
+		{Environment: "dev1", Incidents: 2, Recovered: 1, MeanTimeToRecovery: 48 * time.Hour, Ongoing: true},
+	}, Recoveries(entries))
+}
+
+func TestRecoveries(t *testing.T) {
+	assert.Equal(t, []Recovery{
+		{Environment: "dev1", Incidents: 2, Recovered: 1, MeanTimeToRecovery: 48 * time.Hour, Ongoing: true},
+		{Environment: "dev2", Incidents: 1, Recovered: 1, MeanTimeToRecovery: 5 * 24 * time.Hour},
+	}, Recoveries(testEntries))
+}
+
+func TestTopNamespaces(t *testing.T) {
+	assert.Equal(t, []NamespaceFailures{
+		{Environment: "dev1", Namespace: "dev1-ns", Runs: 4, Failures: 10},
+		{Environment: "dev2", Namespace: "dev2-ns", Runs: 3, Failures: 6},
+	}, TopNamespaces(testEntries))
+}
+
+func TestWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(testEntries[3:], Window(testEntries, 4))
+	assert.Equal(testEntries, Window(testEntries, 0))
+	assert.Equal(testEntries[5:], Window(testEntries, 1))
+}
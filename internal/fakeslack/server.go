@@ -0,0 +1,654 @@
+// Package fakeslack is an in-process fake of the parts of the Slack Web API used by
+// slacker, for driving end-to-end tests without a real workspace. It records every call
+// it receives, and keeps the state of the channels, messages & files posted to it
+package fakeslack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Call is a single request received by the fake
+type Call struct {
+	Method string
+	Values url.Values
+}
+
+// Channel is a conversation known to the fake
+type Channel struct {
+	ID       string
+	Name     string
+	IsMember bool
+}
+
+// User is a workspace member known to the fake, looked up by `users.lookupByEmail`
+type User struct {
+	ID    string
+	Email string
+}
+
+type Server struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	calls       []Call
+	channels    []Channel
+	users       []User
+	messages    map[string][]*slack.Message
+	uploads     map[string]slack.File
+	rateLimited map[string]int
+	token       string
+	scopes      []string
+	teamId      string
+	nextTs      int64
+	nextFile    int
+}
+
+// New starts a fake Slack server, which is closed when the test finishes
+func New(t interface{ Cleanup(func()) }) *Server {
+	s := &Server{
+		messages:    map[string][]*slack.Message{},
+		uploads:     map[string]slack.File{},
+		rateLimited: map[string]int{},
+		teamId:      "T0000FAKE",
+		nextTs:      time.Now().Unix(),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// APIURL is the base URL of the fake Web API, for use with `slack.OptionAPIURL`
+func (s *Server) APIURL() string {
+	return s.server.URL + "/api/"
+}
+
+// WebhookURL is the URL of a fake incoming webhook, which posts to `channel`
+func (s *Server) WebhookURL(channel string) string {
+	return s.server.URL + "/webhook/" + channel
+}
+
+// AddChannel adds a channel, to be returned by `conversations.list`
+func (s *Server) AddChannel(channel Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels = append(s.channels, channel)
+}
+
+// RemoveChannel removes the channel with ID `id`, as if it was deleted
+func (s *Server) RemoveChannel(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels = slices.DeleteFunc(s.channels, func(c Channel) bool { return c.ID == id })
+}
+
+// SetTeam sets the ID of the workspace returned by `auth.test`, `T0000FAKE` by default
+func (s *Server) SetTeam(teamId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.teamId = teamId
+}
+
+// AddUser adds a user, to be found by `users.lookupByEmail`
+func (s *Server) AddUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = append(s.users, user)
+}
+
+// SetToken makes the fake reject every call that isn't made with `token` as `invalid_auth`.
+// Any token is accepted by default
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+}
+
+// SetScopes sets the OAuth scopes granted to the token, returned in the `X-OAuth-Scopes`
+// header of each response. No scopes are returned by default, as for legacy tokens
+func (s *Server) SetScopes(scopes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scopes = scopes
+}
+
+// RateLimit makes the next `times` calls to `method` fail as rate limited
+func (s *Server) RateLimit(method string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimited[method] = times
+}
+
+// Calls returns every call received for `method`, or every call if `method` is empty
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range s.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the calls received so far, keeping the channel state
+func (s *Server) ResetCalls() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = nil
+}
+
+// Messages returns the top-level messages in `channel`, oldest first
+func (s *Server) Messages(channel string) []slack.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := []slack.Message{}
+	for _, msg := range s.messages[channel] {
+		if msg.ThreadTimestamp == "" || msg.ThreadTimestamp == msg.Timestamp {
+			msgs = append(msgs, *msg)
+		}
+	}
+	return msgs
+}
+
+// Replies returns the replies in the thread of `ts` in `channel`, oldest first, excluding
+// the parent message
+func (s *Server) Replies(channel string, ts string) []slack.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := []slack.Message{}
+	for _, msg := range s.messages[channel] {
+		if msg.ThreadTimestamp == ts && msg.Timestamp != ts {
+			msgs = append(msgs, *msg)
+		}
+	}
+	return msgs
+}
+
+// PostMessage adds a message to `channel` directly, as if posted by another client,
+// returning its timestamp
+func (s *Server) PostMessage(channel string, msg slack.Message) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.Channel = channel
+	if msg.Timestamp == "" {
+		msg.Timestamp = s.timestamp()
+	}
+	s.messages[channel] = append(s.messages[channel], &msg)
+	s.sortMessages(channel)
+
+	return msg.Timestamp
+}
+
+//-----------------------------------------------------------------------------------------
+
+type response map[string]interface{}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		s.record("upload", r.Form)
+		fmt.Fprint(w, "OK")
+		return
+
+	case strings.HasPrefix(r.URL.Path, "/webhook/"):
+		s.record("webhook", r.Form)
+		s.handleWebhook(w, r, strings.TrimPrefix(r.URL.Path, "/webhook/"))
+		return
+	}
+
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	s.record(method, r.Form)
+
+	if s.rateLimited[method] > 0 {
+		s.rateLimited[method]--
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	if len(s.scopes) > 0 {
+		w.Header().Set("X-OAuth-Scopes", strings.Join(s.scopes, ","))
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if s.token != "" && token != s.token {
+		writeResponse(w, errorResponse("invalid_auth"))
+		return
+	}
+
+	var resp response
+	switch method {
+	case "auth.test":
+		resp = s.authTest(r.Form)
+	case "chat.postMessage":
+		resp = s.postMessage(r.Form)
+	case "chat.update":
+		resp = s.updateMessage(r.Form)
+	case "chat.delete":
+		resp = s.deleteMessage(r.Form)
+	case "chat.getPermalink":
+		resp = s.getPermalink(r.Form)
+	case "conversations.history":
+		resp = s.history(r.Form)
+	case "conversations.replies":
+		resp = s.replies(r.Form)
+	case "conversations.list":
+		resp = s.listChannels(r.Form)
+	case "conversations.info":
+		resp = s.channelInfo(r.Form)
+	case "users.lookupByEmail":
+		resp = s.lookupUserByEmail(r.Form)
+	case "files.getUploadURLExternal":
+		resp = s.getUploadURL(r.Form)
+	case "files.completeUploadExternal":
+		resp = s.completeUpload(r.Form)
+	case "files.delete":
+		resp = s.deleteFile(r.Form)
+	default:
+		resp = errorResponse("unknown_method")
+	}
+
+	writeResponse(w, resp)
+}
+
+func writeResponse(w http.ResponseWriter, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) record(method string, values url.Values) {
+	s.calls = append(s.calls, Call{Method: method, Values: values})
+}
+
+func errorResponse(err string) response {
+	return response{"ok": false, "error": err}
+}
+
+// timestamp generates a unique, increasing message timestamp
+func (s *Server) timestamp() string {
+	s.nextTs++
+	return fmt.Sprintf("%d.000100", s.nextTs)
+}
+
+func (s *Server) sortMessages(channel string) {
+	sort.SliceStable(s.messages[channel], func(i, j int) bool {
+		return s.messages[channel][i].Timestamp < s.messages[channel][j].Timestamp
+	})
+}
+
+func (s *Server) findMessage(channel string, ts string) *slack.Message {
+	for _, msg := range s.messages[channel] {
+		if msg.Timestamp == ts {
+			return msg
+		}
+	}
+	return nil
+}
+
+// applyContent sets the content of `msg` from the posted form `values`
+func applyContent(msg *slack.Message, values url.Values) error {
+	msg.Text = values.Get("text")
+	msg.Username = values.Get("username")
+
+	msg.Blocks = slack.Blocks{}
+	if blocks := values.Get("blocks"); blocks != "" {
+		if err := json.Unmarshal([]byte(blocks), &msg.Blocks); err != nil {
+			return err
+		}
+	}
+
+	msg.Attachments = nil
+	if attachments := values.Get("attachments"); attachments != "" {
+		if err := json.Unmarshal([]byte(attachments), &msg.Attachments); err != nil {
+			return err
+		}
+	}
+
+	if metadata := values.Get("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &msg.Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) authTest(values url.Values) response {
+	return response{
+		"ok":      true,
+		"url":     "https://fake.slack.com/",
+		"team":    "Fake",
+		"team_id": s.teamId,
+		"user":    "slacker",
+		"user_id": "U0000FAKE",
+	}
+}
+
+func (s *Server) lookupUserByEmail(values url.Values) response {
+	email := values.Get("email")
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return response{
+				"ok": true,
+				"user": map[string]interface{}{
+					"id":      user.ID,
+					"profile": map[string]interface{}{"email": user.Email},
+				},
+			}
+		}
+	}
+	return errorResponse("users_not_found")
+}
+
+func (s *Server) postMessage(values url.Values) response {
+	channel := values.Get("channel")
+	if channel == "" {
+		return errorResponse("channel_not_found")
+	}
+
+	msg := &slack.Message{}
+	msg.Channel = channel
+	msg.Timestamp = s.timestamp()
+	msg.ThreadTimestamp = values.Get("thread_ts")
+
+	if msg.ThreadTimestamp != "" && s.findMessage(channel, msg.ThreadTimestamp) == nil {
+		return errorResponse("thread_not_found")
+	}
+	if err := applyContent(msg, values); err != nil {
+		return errorResponse("invalid_blocks")
+	}
+
+	s.messages[channel] = append(s.messages[channel], msg)
+
+	return response{"ok": true, "channel": channel, "ts": msg.Timestamp, "message": msg}
+}
+
+func (s *Server) updateMessage(values url.Values) response {
+	channel := values.Get("channel")
+
+	msg := s.findMessage(channel, values.Get("ts"))
+	if msg == nil {
+		return errorResponse("message_not_found")
+	}
+	if err := applyContent(msg, values); err != nil {
+		return errorResponse("invalid_blocks")
+	}
+
+	return response{"ok": true, "channel": channel, "ts": msg.Timestamp, "text": msg.Text}
+}
+
+func (s *Server) deleteMessage(values url.Values) response {
+	channel, ts := values.Get("channel"), values.Get("ts")
+
+	for i, msg := range s.messages[channel] {
+		if msg.Timestamp == ts {
+			s.messages[channel] = append(s.messages[channel][:i], s.messages[channel][i+1:]...)
+			return response{"ok": true, "channel": channel, "ts": ts}
+		}
+	}
+
+	return errorResponse("message_not_found")
+}
+
+func (s *Server) getPermalink(values url.Values) response {
+	channel, ts := values.Get("channel"), values.Get("message_ts")
+
+	msg := s.findMessage(channel, ts)
+	if msg == nil {
+		return errorResponse("message_not_found")
+	}
+
+	permalink := fmt.Sprintf("https://fake.slack.com/archives/%s/p%s", channel, strings.ReplaceAll(ts, ".", ""))
+	if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != ts {
+		permalink += "?thread_ts=" + msg.ThreadTimestamp
+	}
+
+	return response{"ok": true, "channel": channel, "permalink": permalink}
+}
+
+// paginate returns the page of `msgs` selected by the `cursor` & `limit` values, along with
+// the cursor for the next page
+func paginate(msgs []slack.Message, values url.Values) ([]slack.Message, string) {
+	offset, _ := strconv.Atoi(values.Get("cursor"))
+	limit, _ := strconv.Atoi(values.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	if offset > len(msgs) {
+		offset = len(msgs)
+	}
+	end := offset + limit
+	if end >= len(msgs) {
+		return msgs[offset:], ""
+	}
+
+	return msgs[offset:end], strconv.Itoa(end)
+}
+
+// withoutMetadata strips message metadata unless it was requested
+func withoutMetadata(msgs []slack.Message, values url.Values) []slack.Message {
+	if values.Get("include_all_metadata") == "true" || values.Get("include_all_metadata") == "1" {
+		return msgs
+	}
+	for i := range msgs {
+		msgs[i].Metadata = slack.SlackMetadata{}
+	}
+	return msgs
+}
+
+func (s *Server) history(values url.Values) response {
+	channel := values.Get("channel")
+	oldest, _ := strconv.ParseFloat(values.Get("oldest"), 64)
+
+	// History is returned newest first
+	msgs := []slack.Message{}
+	for i := len(s.messages[channel]) - 1; i >= 0; i-- {
+		msg := s.messages[channel][i]
+		if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+			continue
+		}
+		if ts, _ := strconv.ParseFloat(msg.Timestamp, 64); ts < oldest {
+			continue
+		}
+		msgs = append(msgs, *msg)
+	}
+
+	page, nextCursor := paginate(msgs, values)
+
+	return response{
+		"ok":                true,
+		"messages":          withoutMetadata(page, values),
+		"has_more":          nextCursor != "",
+		"response_metadata": response{"next_cursor": nextCursor},
+	}
+}
+
+func (s *Server) replies(values url.Values) response {
+	channel, ts := values.Get("channel"), values.Get("ts")
+
+	if s.findMessage(channel, ts) == nil {
+		return errorResponse("thread_not_found")
+	}
+
+	// Replies are returned oldest first, starting with the parent message
+	msgs := []slack.Message{}
+	for _, msg := range s.messages[channel] {
+		if msg.Timestamp == ts || msg.ThreadTimestamp == ts {
+			msgs = append(msgs, *msg)
+		}
+	}
+
+	page, nextCursor := paginate(msgs, values)
+
+	return response{
+		"ok":                true,
+		"messages":          withoutMetadata(page, values),
+		"has_more":          nextCursor != "",
+		"response_metadata": response{"next_cursor": nextCursor},
+	}
+}
+
+func (s *Server) listChannels(values url.Values) response {
+	offset, _ := strconv.Atoi(values.Get("cursor"))
+	limit, _ := strconv.Atoi(values.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	channels := []response{}
+	for i := offset; i < len(s.channels) && i < offset+limit; i++ {
+		channels = append(channels, response{
+			"id":        s.channels[i].ID,
+			"name":      s.channels[i].Name,
+			"is_member": s.channels[i].IsMember,
+		})
+	}
+
+	nextCursor := ""
+	if offset+limit < len(s.channels) {
+		nextCursor = strconv.Itoa(offset + limit)
+	}
+
+	return response{
+		"ok":                true,
+		"channels":          channels,
+		"response_metadata": response{"next_cursor": nextCursor},
+	}
+}
+
+func (s *Server) channelInfo(values url.Values) response {
+	for _, channel := range s.channels {
+		if channel.ID == values.Get("channel") {
+			return response{
+				"ok": true,
+				"channel": response{
+					"id":        channel.ID,
+					"name":      channel.Name,
+					"is_member": channel.IsMember,
+				},
+			}
+		}
+	}
+	return errorResponse("channel_not_found")
+}
+
+func (s *Server) getUploadURL(values url.Values) response {
+	s.nextFile++
+	id := fmt.Sprintf("F%08d", s.nextFile)
+
+	s.uploads[id] = slack.File{ID: id, Name: values.Get("filename")}
+
+	return response{"ok": true, "file_id": id, "upload_url": s.server.URL + "/upload/" + id}
+}
+
+func (s *Server) completeUpload(values url.Values) response {
+	var summaries []slack.FileSummary
+	if err := json.Unmarshal([]byte(values.Get("files")), &summaries); err != nil {
+		return errorResponse("invalid_arguments")
+	}
+
+	channel, threadTs := values.Get("channel_id"), values.Get("thread_ts")
+	if threadTs != "" && s.findMessage(channel, threadTs) == nil {
+		return errorResponse("thread_not_found")
+	}
+
+	msg := &slack.Message{}
+	msg.Channel = channel
+	msg.Timestamp = s.timestamp()
+	msg.ThreadTimestamp = threadTs
+
+	for _, summary := range summaries {
+		file, ok := s.uploads[summary.ID]
+		if !ok {
+			return errorResponse("file_not_found")
+		}
+		file.Title = summary.Title
+		msg.Files = append(msg.Files, file)
+	}
+
+	s.messages[channel] = append(s.messages[channel], msg)
+
+	return response{"ok": true, "files": summaries}
+}
+
+func (s *Server) deleteFile(values url.Values) response {
+	id := values.Get("file")
+
+	for channel, msgs := range s.messages {
+		for i, msg := range msgs {
+			for j, file := range msg.Files {
+				if file.ID != id {
+					continue
+				}
+
+				msg.Files = append(msg.Files[:j], msg.Files[j+1:]...)
+				if len(msg.Files) == 0 {
+					s.messages[channel] = append(msgs[:i], msgs[i+1:]...)
+				}
+				return response{"ok": true}
+			}
+		}
+	}
+
+	return errorResponse("file_not_found")
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request, channel string) {
+	var webhookMsg slack.WebhookMessage
+	if err := json.NewDecoder(r.Body).Decode(&webhookMsg); err != nil {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+		return
+	}
+
+	msg := &slack.Message{}
+	msg.Channel = channel
+	msg.Timestamp = s.timestamp()
+	msg.Text = webhookMsg.Text
+	msg.Username = webhookMsg.Username
+	msg.Attachments = webhookMsg.Attachments
+	if webhookMsg.Blocks != nil {
+		msg.Blocks = *webhookMsg.Blocks
+	}
+
+	s.messages[channel] = append(s.messages[channel], msg)
+
+	fmt.Fprint(w, "ok")
+}
@@ -0,0 +1,168 @@
+package report
+
+// Change describes how a failure changed since the previous report
+type Change string
+
+const (
+	ChangeNew        Change = "new"
+	ChangePersistent Change = "persistent"
+	ChangeResolved   Change = "resolved"
+)
+
+// FailureDiff is a failure in either the current or previous report, matched by its ID
+type FailureDiff struct {
+	Failure Failure
+	Change  Change
+}
+
+// SectionDiff lists the failures of a section, in their current order followed by any
+// resolved failures
+type SectionDiff struct {
+	Icon      string
+	Name      string
+	Threshold int
+	Failures  []FailureDiff
+}
+
+// NamespaceDiff lists the sections of a namespace, in their current order followed by any
+// sections only in the previous report
+type NamespaceDiff struct {
+	Name     string
+	Sections []SectionDiff
+}
+
+// EnvironmentDiff compares the failures of an environment with the previous report
+type EnvironmentDiff struct {
+	Namespaces []NamespaceDiff
+
+	New        int
+	Persistent int
+	Resolved   int
+}
+
+// Environment returns the environment named `name`, or nil if it isn't in the report
+func (r *ReportJson) Environment(name string) *ReportEnvironment {
+	for i := range r.Environments {
+		if r.Environments[i].Name == name {
+			return &r.Environments[i]
+		}
+	}
+	return nil
+}
+
+// DiffEnvironment compares `env` with the same environment in the `previous` report.
+// Nil is returned if there is no previous report, the environment is missing from it (eg. as
+// its failures couldn't be recovered), or either environment hasn't completed, as there's
+// nothing to compare
+func DiffEnvironment(previous *ReportJson, env ReportEnvironment) *EnvironmentDiff {
+	if previous == nil || env.Status != Completed {
+		return nil
+	}
+
+	previousEnv := previous.Environment(env.Name)
+	if previousEnv == nil || previousEnv.Status != Completed {
+		return nil
+	}
+
+	diff := &EnvironmentDiff{}
+
+	for _, ns := range mergeNamespaces(env.Namespaces, previousEnv.Namespaces) {
+		nsDiff := NamespaceDiff{Name: ns.name}
+
+		for _, section := range mergeSections(ns.current, ns.previous) {
+			sectionDiff := SectionDiff{Icon: section.icon, Name: section.name, Threshold: section.threshold}
+
+			previousFailures := map[string]bool{}
+			for _, failure := range section.previous {
+				previousFailures[failure.Id] = true
+			}
+
+			currentFailures := map[string]bool{}
+			for _, failure := range section.current {
+				currentFailures[failure.Id] = true
+
+				change := ChangeNew
+				if previousFailures[failure.Id] {
+					change = ChangePersistent
+					diff.Persistent++
+				} else {
+					diff.New++
+				}
+				sectionDiff.Failures = append(sectionDiff.Failures, FailureDiff{Failure: failure, Change: change})
+			}
+
+			for _, failure := range section.previous {
+				if !currentFailures[failure.Id] {
+					diff.Resolved++
+					sectionDiff.Failures = append(sectionDiff.Failures, FailureDiff{Failure: failure, Change: ChangeResolved})
+				}
+			}
+
+			nsDiff.Sections = append(nsDiff.Sections, sectionDiff)
+		}
+
+		diff.Namespaces = append(diff.Namespaces, nsDiff)
+	}
+
+	return diff
+}
+
+// mergedNamespace pairs the sections of a namespace in the current & previous reports
+type mergedNamespace struct {
+	name     string
+	current  []Section
+	previous []Section
+}
+
+// mergeNamespaces pairs namespaces by name, in their current order followed by namespaces
+// only in the previous report
+func mergeNamespaces(current []Namespace, previous []Namespace) []mergedNamespace {
+	merged := []mergedNamespace{}
+	index := map[string]int{}
+
+	for _, ns := range current {
+		index[ns.Name] = len(merged)
+		merged = append(merged, mergedNamespace{name: ns.Name, current: ns.Sections})
+	}
+
+	for _, ns := range previous {
+		if i, ok := index[ns.Name]; ok {
+			merged[i].previous = ns.Sections
+		} else {
+			merged = append(merged, mergedNamespace{name: ns.Name, previous: ns.Sections})
+		}
+	}
+
+	return merged
+}
+
+// mergedSection pairs the failures of a section in the current & previous reports
+type mergedSection struct {
+	icon      string
+	name      string
+	threshold int
+	current   []Failure
+	previous  []Failure
+}
+
+// mergeSections pairs sections by name, in their current order followed by sections only
+// in the previous report
+func mergeSections(current []Section, previous []Section) []mergedSection {
+	merged := []mergedSection{}
+	index := map[string]int{}
+
+	for _, s := range current {
+		index[s.Name] = len(merged)
+		merged = append(merged, mergedSection{icon: s.Icon, name: s.Name, threshold: s.Threshold, current: s.Failures})
+	}
+
+	for _, s := range previous {
+		if i, ok := index[s.Name]; ok {
+			merged[i].previous = s.Failures
+		} else {
+			merged = append(merged, mergedSection{icon: s.Icon, name: s.Name, previous: s.Failures})
+		}
+	}
+
+	return merged
+}
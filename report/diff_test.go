@@ -0,0 +1,75 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffEnvironment(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := &ReportJson{
+		Environments: []ReportEnvironment{
+			{Name: "dev1", Status: Completed, Namespaces: []Namespace{
+				{Name: "ns1", Sections: []Section{
+					{Name: "Failed Pods", Failures: NewFailures("foo", "bar")},
+				}},
+				{Name: "ns2", Sections: []Section{
+					{Name: "Failed Jobs", Failures: NewFailures("baz")},
+				}},
+			}},
+			{Name: "dev2", Status: Pending},
+		},
+	}
+
+	diff := DiffEnvironment(previous, ReportEnvironment{
+		Name: "dev1", Status: Completed, Namespaces: []Namespace{
+			{Name: "ns1", Sections: []Section{
+				{Name: "Failed Pods", Failures: NewFailures("qux", "foo")},
+			}},
+		},
+	})
+
+	assert.Equal(&EnvironmentDiff{
+		Namespaces: []NamespaceDiff{
+			{Name: "ns1", Sections: []SectionDiff{
+				{Name: "Failed Pods", Failures: []FailureDiff{
+					{Failure: Failure{Id: "qux"}, Change: ChangeNew},
+					{Failure: Failure{Id: "foo"}, Change: ChangePersistent},
+					{Failure: Failure{Id: "bar"}, Change: ChangeResolved},
+				}},
+			}},
+			{Name: "ns2", Sections: []SectionDiff{
+				{Name: "Failed Jobs", Failures: []FailureDiff{
+					{Failure: Failure{Id: "baz"}, Change: ChangeResolved},
+				}},
+			}},
+		},
+		New:        1,
+		Persistent: 1,
+		Resolved:   2,
+	}, diff)
+}
+
+func TestDiffEnvironmentWithoutComparison(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := &ReportJson{
+		Environments: []ReportEnvironment{
+			{Name: "dev2", Status: Pending},
+		},
+	}
+	env := func(name string) ReportEnvironment {
+		return ReportEnvironment{Name: name, Status: Completed, Namespaces: []Namespace{
+			{Name: "ns1", Sections: []Section{{Name: "Failed Pods", Failures: NewFailures("foo")}}},
+		}}
+	}
+
+	assert.Nil(DiffEnvironment(nil, env("dev1")))
+	assert.Nil(DiffEnvironment(previous, env("dev2")))
+	assert.Nil(DiffEnvironment(previous, ReportEnvironment{Name: "dev1", Status: Pending}))
+	// Environments missing from the previous report may just not have been recovered, so
+	// their failures aren't all new
+	assert.Nil(DiffEnvironment(previous, env("dev1")))
+}
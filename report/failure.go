@@ -0,0 +1,102 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityError    Severity = "error"
+	SeverityWarning  Severity = "warning"
+)
+
+// Severities are all the valid failure severities
+var Severities = []Severity{SeverityCritical, SeverityError, SeverityWarning}
+
+// IsValid returns whether `s` is one of the known `Severities`
+func (s Severity) IsValid() bool {
+	for _, severity := range Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// joinSeverities lists the valid severities for error messages
+func joinSeverities() string {
+	severities := make([]string, len(Severities))
+	for i, severity := range Severities {
+		severities[i] = fmt.Sprintf("'%s'", severity)
+	}
+	return strings.Join(severities, ", ")
+}
+
+// Failure is a single failure within a section. In the report JSON, a failure is either just
+// its ID as a string, or an object describing it
+type Failure struct {
+	// Id identifies the failure, eg. the failing pod, and is used to match failures between
+	// reports
+	Id string `json:"id" jsonschema:"required,nonempty"`
+
+	Message string `json:"message,omitempty"`
+	// Severity defaults to `error` when not set
+	Severity Severity `json:"severity,omitempty"`
+	// Url links to more details, eg. the failure's logs
+	Url    string   `json:"url,omitempty"`
+	Owner  string   `json:"owner,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// failureObject is the object form of a `Failure`, without its JSON methods
+type failureObject Failure
+
+func (f *Failure) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*f = Failure{Id: id}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*failureObject)(f))
+}
+
+// MarshalJSON writes failures with only an ID as a string, as they are usually given
+func (f Failure) MarshalJSON() ([]byte, error) {
+	if f.isPlain() {
+		return json.Marshal(f.Id)
+	}
+	return json.Marshal(failureObject(f))
+}
+
+// isPlain returns whether the failure has nothing but its ID
+func (f Failure) isPlain() bool {
+	return f.Message == "" && f.Severity == "" && f.Url == "" && f.Owner == "" && len(f.Labels) == 0
+}
+
+// EffectiveSeverity is the failure's severity, defaulting to `error`
+func (f Failure) EffectiveSeverity() Severity {
+	if f.Severity == "" {
+		return SeverityError
+	}
+	return f.Severity
+}
+
+// IsError returns whether the failure makes its environment unhealthy, rather than being a
+// warning
+func (f Failure) IsError() bool {
+	return f.EffectiveSeverity() != SeverityWarning
+}
+
+// NewFailures builds plain failures from their `ids`
+func NewFailures(ids ...string) []Failure {
+	failures := make([]Failure, len(ids))
+	for i, id := range ids {
+		failures[i] = Failure{Id: id}
+	}
+	return failures
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureAcceptsStringOrObject(t *testing.T) {
+	assert := assert.New(t)
+
+	failures := []Failure{}
+	require.NoError(t, json.Unmarshal([]byte(`[
+		"foo",
+		{"id": "bar", "message": "CrashLoopBackOff", "severity": "warning", "url": "https://logs/bar", "owner": "team-a", "labels": ["flaky"]}
+	]`), &failures))
+
+	assert.Equal([]Failure{
+		{Id: "foo"},
+		{Id: "bar", Message: "CrashLoopBackOff", Severity: SeverityWarning, Url: "https://logs/bar", Owner: "team-a", Labels: []string{"flaky"}},
+	}, failures)
+
+	// Failures with only an ID are written back as strings
+	bytes, err := json.Marshal(failures)
+	require.NoError(t, err)
+	assert.JSONEq(`[
+		"foo",
+		{"id": "bar", "message": "CrashLoopBackOff", "severity": "warning", "url": "https://logs/bar", "owner": "team-a", "labels": ["flaky"]}
+	]`, string(bytes))
+}
+
+func TestEnvironmentCountsFailuresBySeverity(t *testing.T) {
+	assert := assert.New(t)
+
+	env := ReportEnvironment{Name: "dev1", Status: Completed, Namespaces: []Namespace{
+		{Name: "ns1", Sections: []Section{{Name: "Failed Pods", Failures: []Failure{
+			{Id: "a"},
+			{Id: "b", Severity: SeverityCritical},
+			{Id: "c", Severity: SeverityError},
+			{Id: "d", Severity: SeverityWarning},
+		}}}},
+	}}
+
+	assert.Equal(3, env.Errors())
+	assert.Equal(1, env.Warnings())
+	assert.Equal(4, env.Failures())
+	assert.False(env.IsHealthy())
+
+	env.Namespaces[0].Sections[0].Failures = []Failure{{Id: "d", Severity: SeverityWarning}}
+	assert.True(env.IsHealthy())
+}
+
+func TestValidateFailures(t *testing.T) {
+	report, errs := Validate([]byte(`{
+		"environments": [
+			{"name": "dev1", "status": "completed", "namespaces": [
+				{"name": "ns1", "sections": [{"name": "Failed Pods", "failures": [
+					"",
+					{"message": "no id"},
+					{"id": "a", "severity": "fatal"},
+					{"id": "b", "colour": "red"},
+					3
+				]}]}
+			]}
+		]
+	}`))
+
+	assert.Nil(t, report)
+	assert.Equal(t, []string{
+		"$.environments[0].namespaces[0].sections[0].failures[1].id: required field is missing",
+		"$.environments[0].namespaces[0].sections[0].failures[3].colour: unknown field",
+		"$.environments[0].namespaces[0].sections[0].failures[4]: expected string or object, got number",
+		"$.environments[0].namespaces[0].sections[0].failures[0].id: failure id is empty",
+		"$.environments[0].namespaces[0].sections[0].failures[2].severity: unknown severity 'fatal', expected one of 'critical', 'error', 'warning'",
+	}, validationMessages(errs))
+}
+
+func TestSectionThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	section := Section{Name: "Failed Pods", Threshold: 2, Failures: []Failure{
+		{Id: "a"},
+		{Id: "b", Severity: SeverityCritical},
+	}}
+
+	// Within the threshold, only failures with an explicit error severity are errors
+	assert.True(section.WithinThreshold())
+	assert.Equal(1, section.Errors())
+	assert.Equal(1, section.Warnings())
+
+	section.Failures = append(section.Failures, Failure{Id: "c"})
+	assert.False(section.WithinThreshold())
+	assert.Equal(3, section.Errors())
+	assert.Equal(0, section.Warnings())
+}
+
+func TestEnvironmentHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	env := ReportEnvironment{Name: "dev1", Status: Completed, Namespaces: []Namespace{
+		{Name: "ns1", Sections: []Section{{Name: "Failed Pods", Threshold: 2, Failures: NewFailures("a", "b")}}},
+	}}
+	assert.Equal(Degraded, env.Health())
+	assert.True(env.IsHealthy())
+
+	env.Namespaces[0].Sections[0].Failures = NewFailures("a", "b", "c")
+	assert.Equal(Unhealthy, env.Health())
+	assert.False(env.IsHealthy())
+
+	env.Namespaces[0].Sections[0].Failures = nil
+	assert.Equal(Healthy, env.Health())
+}
+
+func TestValidateRejectsNegativeThreshold(t *testing.T) {
+	_, errs := Validate([]byte(`{
+		"environments": [
+			{"name": "dev1", "status": "completed", "namespaces": [
+				{"name": "ns1", "sections": [{"name": "Failed Pods", "threshold": -1, "failures": []}]}
+			]}
+		]
+	}`))
+
+	assert.Equal(t, []string{
+		"$.environments[0].namespaces[0].sections[0].threshold: threshold must not be negative",
+	}, validationMessages(errs))
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"fmt"
+	"path"
+)
+
+// OwnerRule assigns `Owners` to the failures matching each of its patterns. Patterns are
+// globs as used by `path.Match`, eg. `abx-*`, and an empty pattern matches anything
+type OwnerRule struct {
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Section   string `json:"section,omitempty" yaml:"section,omitempty"`
+	Failure   string `json:"failure,omitempty" yaml:"failure,omitempty"`
+
+	// Owners are Slack user or user group IDs, mentions such as `<@U0123ABCD>`, emails or
+	// handles
+	Owners []string `json:"owners" yaml:"owners" jsonschema:"required,nonempty"`
+}
+
+// Matches returns whether `failure` in `section` of `namespace` matches the rule
+func (r *OwnerRule) Matches(namespace string, section string, failure string) bool {
+	return matchPattern(r.Namespace, namespace) &&
+		matchPattern(r.Section, section) &&
+		matchPattern(r.Failure, failure)
+}
+
+func matchPattern(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// validate checks the rule's patterns & owners, at the JSON `rulePath`
+func (r *OwnerRule) validate(rulePath string) []error {
+	errs := []error{}
+
+	patterns := []struct{ field, pattern string }{
+		{"namespace", r.Namespace},
+		{"section", r.Section},
+		{"failure", r.Failure},
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p.pattern, ""); err != nil {
+			errs = append(errs, validationErrorf(rulePath+"."+p.field, "invalid pattern '%s': %v", p.pattern, err))
+		}
+	}
+
+	if len(r.Owners) == 0 {
+		errs = append(errs, validationErrorf(rulePath+".owners", "rule has no owners"))
+	}
+	for i, owner := range r.Owners {
+		if owner == "" {
+			errs = append(errs, validationErrorf(fmt.Sprintf("%s.owners[%d]", rulePath, i), "owner is empty"))
+		}
+	}
+
+	return errs
+}
+
+// ValidateOwnerRules checks rules read from outside of a report, eg. an owners file
+func ValidateOwnerRules(rules []OwnerRule) []error {
+	errs := []error{}
+	for i, rule := range rules {
+		errs = append(errs, rule.validate(fmt.Sprintf("$[%d]", i))...)
+	}
+	return errs
+}
+
+// Owners returns the owners of the failing namespaces, sections & failures of `env`, keyed by
+// namespace, along with the owners of the whole environment in the order first found. Only
+// failures that are errors are owned, as warnings don't need anyone's attention. A failure's
+// own `Owner` comes first, followed by the owners of each matching rule
+func (env *ReportEnvironment) Owners(rules []OwnerRule) (byNamespace map[string][]string, all []string) {
+	var (
+		seen      = map[string]bool{}
+		nsSeen    map[string]bool
+		addOwners = func(ns string, owners ...string) {
+			for _, owner := range owners {
+				if owner == "" {
+					continue
+				}
+				if !nsSeen[owner] {
+					nsSeen[owner] = true
+					byNamespace[ns] = append(byNamespace[ns], owner)
+				}
+				if !seen[owner] {
+					seen[owner] = true
+					all = append(all, owner)
+				}
+			}
+		}
+	)
+	byNamespace = map[string][]string{}
+
+	for _, ns := range env.Namespaces {
+		nsSeen = map[string]bool{}
+
+		for i := range ns.Sections {
+			section := &ns.Sections[i]
+
+			for _, failure := range section.Failures {
+				if !section.IsError(failure) {
+					continue
+				}
+
+				addOwners(ns.Name, failure.Owner)
+				for _, rule := range rules {
+					if rule.Matches(ns.Name, section.Name, failure.Id) {
+						addOwners(ns.Name, rule.Owners...)
+					}
+				}
+			}
+		}
+	}
+
+	return byNamespace, all
+}
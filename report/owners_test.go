@@ -0,0 +1,77 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerRuleMatchesGlobs(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := OwnerRule{Namespace: "abx-*", Failure: "db-?"}
+	assert.True(rule.Matches("abx-foo", "Failed Pods", "db-1"))
+	assert.False(rule.Matches("xyz-foo", "Failed Pods", "db-1"))
+	assert.False(rule.Matches("abx-foo", "Failed Pods", "db-10"))
+
+	// Empty patterns match anything
+	assert.True((&OwnerRule{}).Matches("ns1", "Failed Pods", "foo"))
+}
+
+func TestEnvironmentOwnersOfErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	env := ReportEnvironment{Name: "dev1", Status: Completed, Namespaces: []Namespace{
+		{Name: "abx-1", Sections: []Section{
+			{Name: "Failed Pods", Failures: []Failure{{Id: "foo", Owner: "U0ALICE123"}, {Id: "bar"}}},
+			{Name: "Failed Deployments", Threshold: 5, Failures: []Failure{{Id: "baz"}}},
+		}},
+		{Name: "abx-2", Sections: []Section{
+			{Name: "Failed Pods", Failures: []Failure{{Id: "foo"}}},
+		}},
+		{Name: "xyz-1", Sections: []Section{
+			{Name: "Failed Pods", Failures: []Failure{{Id: "qux", Severity: SeverityWarning}}},
+		}},
+	}}
+
+	byNamespace, all := env.Owners([]OwnerRule{
+		{Namespace: "abx-*", Owners: []string{"S0PODS1234"}},
+		{Section: "Failed Deployments", Owners: []string{"deployers@example.com"}},
+		{Failure: "foo", Owners: []string{"U0ALICE123", "U0BOB12345"}},
+		{Namespace: "xyz-*", Owners: []string{"U0CAROL123"}},
+	})
+
+	// Failures within the section's threshold & warnings aren't owned
+	assert.Equal(map[string][]string{
+		"abx-1": {"U0ALICE123", "S0PODS1234", "U0BOB12345"},
+		"abx-2": {"S0PODS1234", "U0ALICE123", "U0BOB12345"},
+	}, byNamespace)
+	assert.Equal([]string{"U0ALICE123", "S0PODS1234", "U0BOB12345"}, all)
+}
+
+func TestValidateOwnerRules(t *testing.T) {
+	assert.Equal(t, []string{
+		"$[0].namespace: invalid pattern '[': syntax error in pattern",
+		"$[0].owners: rule has no owners",
+		"$[1].owners[1]: owner is empty",
+	}, validationMessages(ValidateOwnerRules([]OwnerRule{
+		{Namespace: "["},
+		{Section: "Failed Pods", Owners: []string{"U0ALICE123", ""}},
+	})))
+}
+
+func TestValidateReportOwners(t *testing.T) {
+	report, errs := Validate([]byte(`{
+		"environments": [],
+		"owners": [
+			{"namespace": "abx-*", "owners": ["S0PODS1234"]},
+			{"failure": "[", "owners": []}
+		]
+	}`))
+
+	assert.Nil(t, report)
+	assert.Equal(t, []string{
+		"$.owners[1].failure: invalid pattern '[': syntax error in pattern",
+		"$.owners[1].owners: rule has no owners",
+	}, validationMessages(errs))
+}
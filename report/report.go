@@ -1,77 +1,285 @@
 package report
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
+// DateFormat is the dd-mm-yyyy format used for report dates
+const DateFormat = "02-01-2006"
+
 type Status string
 
 const (
 	Pending   Status = "pending"
-	Completed        = "completed"
-	Errored          = "errored"
+	Running   Status = "running"
+	Completed Status = "completed"
+	Errored   Status = "errored"
+	Skipped   Status = "skipped"
 )
 
+// Statuses are all the valid environment statuses
+var Statuses = []Status{Pending, Running, Completed, Errored, Skipped}
+
+// IsValid returns whether `s` is one of the known `Statuses`
+func (s Status) IsValid() bool {
+	for _, status := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 // ReportJson is the entire report collected from a file or stdin
 type ReportJson struct {
-	Environments []ReportEnvironment `json:"environments"`
+	Metadata     ReportMetadata      `json:"metadata"`
+	Environments []ReportEnvironment `json:"environments" jsonschema:"required"`
+
+	// Owners assign owners to failures, who are mentioned in the environment reports
+	Owners []OwnerRule `json:"owners,omitempty"`
 }
 
+// ReportMetadata describes the run that produced the report
+type ReportMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Date        string `json:"date,omitempty"`
+	JobName     string `json:"job_name,omitempty"`
+	BuildNumber string `json:"build_number,omitempty"`
+	BuildUrl    string `json:"build_url,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	GitCommit   string `json:"git_commit,omitempty"`
+}
+
+// FromJson strictly decodes a report, rejecting unknown fields, missing required fields
+// and values of the wrong type
 func FromJson(data []byte) (*ReportJson, error) {
-	report := ReportJson{}
-	err := json.Unmarshal(data, &report)
-	return &report, err
+	report, errs := decode(data)
+	return report, errors.Join(errs...)
 }
 
+// ValidateReport checks the values within a decoded report, returning every problem found
 func (r *ReportJson) ValidateReport() []error {
-	errors := []error{}
+	errs := []error{}
 
+	envNames := map[string]int{}
 	for envi, env := range r.Environments {
+		path := fmt.Sprintf("$.environments[%d]", envi)
+
 		if env.Name == "" {
-			errors = append(errors, fmt.Errorf("environment %d is empty", envi))
+			errs = append(errs, validationErrorf(path+".name", "environment name is empty"))
+		} else if previous, ok := envNames[env.Name]; ok {
+			errs = append(errs, validationErrorf(path+".name", "duplicate environment '%s', also at $.environments[%d]", env.Name, previous))
+		} else {
+			envNames[env.Name] = envi
+		}
+
+		if !env.Status.IsValid() {
+			errs = append(errs, validationErrorf(path+".status", "unknown status '%s', expected one of %s", env.Status, joinStatuses()))
+		}
+
+		if env.StartedAt != nil && env.FinishedAt != nil && env.FinishedAt.Before(*env.StartedAt) {
+			errs = append(errs, validationErrorf(path+".finished_at", "finished before it started at %s", env.StartedAt.Format(time.RFC3339)))
+		}
+
+		nsNames := map[string]int{}
+		for nsi, ns := range env.Namespaces {
+			nsPath := fmt.Sprintf("%s.namespaces[%d]", path, nsi)
+
+			if ns.Name == "" {
+				errs = append(errs, validationErrorf(nsPath+".name", "namespace name is empty"))
+			} else if previous, ok := nsNames[ns.Name]; ok {
+				errs = append(errs, validationErrorf(nsPath+".name", "duplicate namespace '%s', also at %s.namespaces[%d]", ns.Name, path, previous))
+			} else {
+				nsNames[ns.Name] = nsi
+			}
+
+			if len(ns.Sections) == 0 {
+				errs = append(errs, validationErrorf(nsPath+".sections", "namespace has no sections"))
+			}
+
+			for si, s := range ns.Sections {
+				sPath := fmt.Sprintf("%s.sections[%d]", nsPath, si)
+
+				if s.Name == "" {
+					errs = append(errs, validationErrorf(sPath+".name", "section name is empty"))
+				}
+				if s.Threshold < 0 {
+					errs = append(errs, validationErrorf(sPath+".threshold", "threshold must not be negative"))
+				}
+
+				for fi, failure := range s.Failures {
+					fPath := fmt.Sprintf("%s.failures[%d]", sPath, fi)
+
+					if failure.Id == "" {
+						errs = append(errs, validationErrorf(fPath+".id", "failure id is empty"))
+					}
+					if failure.Severity != "" && !failure.Severity.IsValid() {
+						errs = append(errs, validationErrorf(fPath+".severity", "unknown severity '%s', expected one of %s", failure.Severity, joinSeverities()))
+					}
+				}
+			}
 		}
 	}
 
-	return errors
+	for i, rule := range r.Owners {
+		errs = append(errs, rule.validate(fmt.Sprintf("$.owners[%d]", i))...)
+	}
+
+	return errs
+}
+
+// joinStatuses lists the valid statuses for error messages
+func joinStatuses() string {
+	statuses := make([]string, len(Statuses))
+	for i, status := range Statuses {
+		statuses[i] = fmt.Sprintf("'%s'", status)
+	}
+	return strings.Join(statuses, ", ")
 }
 
 // ReportConfig is additional config & metadata for the report
 type ReportConfig struct {
-	ReportDate string `json:"date"`
-	BaseUrl    string `json:"base_url"`
+	ReportDate         string `json:"date"`
+	BaseUrl            string `json:"base_url"`
+	PreviousReportDate string `json:"previous_report_date"`
+	PreviousReportUrl  string `json:"previous_report_url"`
+	LearnMoreUrl       string `json:"learn_more_url"`
+
+	// Kind distinguishes different kinds of report sent to the same channel, eg. nightly smoke
+	// tests & bring-up checks, so that each only finds its own reports. Empty is the default
+	// kind, which includes reports sent before kinds existed
+	Kind string `json:"kind,omitempty"`
+
+	// PreviousReport is compared with this report to highlight new & resolved failures, when
+	// available
+	PreviousReport *ReportJson `json:"-"`
+
+	// OwnerRules assign owners to failures, from the report's own `Owners` & any others, eg. an
+	// owners file
+	OwnerRules []OwnerRule `json:"-"`
+
+	// Mentions are the Slack mentions of each owner, eg. `<@U0123ABCD>`. Owners without a
+	// mention aren't mentioned
+	Mentions map[string]string `json:"-"`
+
+	// PastReports are the reports sent over the last `TrendDays` days, oldest first, used to
+	// show each environment's trend when available
+	PastReports []PastReport `json:"-"`
+	TrendDays   int          `json:"trend_days"`
 }
 
 // ReportEnvironment describes a specific environment being tested upon
 type ReportEnvironment struct {
-	Name       string      `json:"name"`
-	Status     Status      `json:"status"`
+	Name       string      `json:"name" jsonschema:"required,nonempty"`
+	Status     Status      `json:"status" jsonschema:"required"`
 	Namespaces []Namespace `json:"namespaces"`
+
+	// Error explains why an errored environment failed, or why an environment was skipped
+	Error string `json:"error,omitempty"`
+	// LogUrl links to the logs of the environment's run
+	LogUrl     string     `json:"log_url,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
 
+// Errors counts the failures in the environment that are errors, see `Section.IsError`
 func (env *ReportEnvironment) Errors() int {
-	totalErrors := 0
+	return env.countFailures((*Section).Errors)
+}
+
+// Warnings counts the failures in the environment that are only warnings
+func (env *ReportEnvironment) Warnings() int {
+	return env.countFailures((*Section).Warnings)
+}
+
+// Failures counts every failure in the environment, regardless of severity
+func (env *ReportEnvironment) Failures() int {
+	return env.countFailures(func(s *Section) int { return len(s.Failures) })
+}
+
+func (env *ReportEnvironment) countFailures(count func(s *Section) int) int {
+	total := 0
 
 	for _, ns := range env.Namespaces {
-		for _, s := range ns.Sections {
-			totalErrors = totalErrors + len(s.Failures)
+		for i := range ns.Sections {
+			total += count(&ns.Sections[i])
 		}
 	}
 
-	return totalErrors
+	return total
 }
 
+// IsHealthy returns whether the environment has no errors. Degraded environments, with only
+// warnings, are still healthy
 func (env *ReportEnvironment) IsHealthy() bool {
 	return env.Errors() == 0
 }
 
+type Health string
+
+const (
+	Healthy   Health = "healthy"
+	Degraded  Health = "degraded"
+	Unhealthy Health = "unhealthy"
+)
+
+// Health is `Unhealthy` when the environment has any errors, `Degraded` when it only has
+// warnings, and `Healthy` otherwise
+func (env *ReportEnvironment) Health() Health {
+	switch {
+	case env.Errors() > 0:
+		return Unhealthy
+	case env.Warnings() > 0:
+		return Degraded
+	default:
+		return Healthy
+	}
+}
+
 type Namespace struct {
-	Name     string    `json:"name"`
-	Sections []Section `json:"sections"`
+	Name     string    `json:"name" jsonschema:"required,nonempty"`
+	Sections []Section `json:"sections" jsonschema:"required,nonempty"`
 }
 
 type Section struct {
-	Icon     string   `json:"icon"`
-	Name     string   `json:"name"`
-	Failures []string `json:"failures"`
+	Icon     string    `json:"icon"`
+	Name     string    `json:"name" jsonschema:"required,nonempty"`
+	Failures []Failure `json:"failures"`
+
+	// Threshold is the number of failures allowed in the section before it's unhealthy. While
+	// within the threshold, failures without an explicit severity are only warnings
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// WithinThreshold returns whether the section has a threshold that it hasn't exceeded
+func (s *Section) WithinThreshold() bool {
+	return s.Threshold > 0 && len(s.Failures) <= s.Threshold
+}
+
+// IsError returns whether `failure` in the section is an error rather than a warning,
+// considering both its severity & the section's threshold
+func (s *Section) IsError(failure Failure) bool {
+	if failure.Severity == "" && s.WithinThreshold() {
+		return false
+	}
+	return failure.IsError()
+}
+
+// Errors counts the failures in the section that are errors
+func (s *Section) Errors() int {
+	count := 0
+	for _, failure := range s.Failures {
+		if s.IsError(failure) {
+			count++
+		}
+	}
+	return count
+}
+
+// Warnings counts the failures in the section that are only warnings
+func (s *Section) Warnings() int {
+	return len(s.Failures) - s.Errors()
 }
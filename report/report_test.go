@@ -10,12 +10,13 @@ import (
 func TestUnmarshallJson(t *testing.T) {
 	assert := assert.New(t)
 
-	testFile := "examples/minimal.json"
+	testFile := "../examples/minimal.json"
 	bytes, err := os.ReadFile(testFile)
 	if err != nil {
-		t.Errorf("failed to read '%s'", testFile)
+		t.Fatalf("failed to read '%s'", testFile)
 	}
 
 	report, err := FromJson(bytes)
+	assert.NoError(err)
 	assert.Equal(report.Environments[0].Name, "dev1")
 }
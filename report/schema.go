@@ -0,0 +1,107 @@
+package report
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// enumValues are the allowed values of enumerated types
+var enumValues = map[reflect.Type]any{
+	reflect.TypeOf(Pending):          Statuses,
+	reflect.TypeOf(SeverityCritical): Severities,
+}
+
+// JsonSchema generates the JSON Schema of the report format from the report types
+func JsonSchema() map[string]any {
+	schema := typeSchema(reflect.TypeOf(ReportJson{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "slacker report"
+	return schema
+}
+
+// MarshalJsonSchema renders the JSON Schema as it is published
+func MarshalJsonSchema() ([]byte, error) {
+	bytes, err := json.MarshalIndent(JsonSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes, '\n'), nil
+}
+
+// typeSchema generates the schema of type `t`
+func typeSchema(t reflect.Type) map[string]any {
+	if values, ok := enumValues[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	if stringOrObjectTypes[t] {
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			structSchema(t),
+		}}
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+
+	case reflect.Struct:
+		return structSchema(t)
+
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema generates the object schema of struct type `t`
+func structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for _, field := range jsonFields(t) {
+		property := typeSchema(field.Type)
+		if field.NonEmpty {
+			switch field.Type.Kind() {
+			case reflect.String:
+				property["minLength"] = 1
+			case reflect.Slice:
+				property["minItems"] = 1
+			}
+		}
+
+		properties[field.Name] = property
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"time"
+)
+
+// PastReport is the number of errors & health of each completed environment of a report sent
+// before this one, used to show trends. Reports sent before health was recorded only have
+// errors
+type PastReport struct {
+	Date   string
+	Errors map[string]int
+	Health map[string]Health
+}
+
+// trendDay is the day of `env` in the report, if it completed. Without a recorded health, the
+// environment is assumed to be healthy unless it had errors
+func (r PastReport) trendDay(env string) (TrendDay, bool) {
+	errors, ok := r.Errors[env]
+	if !ok {
+		return TrendDay{}, false
+	}
+
+	health, ok := r.Health[env]
+	if !ok {
+		health = Healthy
+		if errors > 0 {
+			health = Unhealthy
+		}
+	}
+
+	return TrendDay{Date: r.Date, Errors: errors, Health: health, Reported: true}, true
+}
+
+// TrendDay is the number of errors & health of an environment on a day. Days without a
+// completed report of the environment aren't `Reported`
+type TrendDay struct {
+	Date     string
+	Errors   int
+	Health   Health
+	Reported bool
+}
+
+// EnvironmentTrend describes how an environment has changed over recent reports
+type EnvironmentTrend struct {
+	// PreviousDate is the date of the most recent earlier report of the environment, or empty
+	// if there isn't one
+	PreviousDate   string
+	PreviousErrors int
+
+	// Streak is the number of consecutive days, including this report, that the environment
+	// has had the same health
+	Streak int
+
+	// Days are the environment's errors on each day of the trend, ending with this report
+	Days []TrendDay
+}
+
+// TrendEnvironment builds the trend of `env` over the `days` days up to & including
+// `reportDate`, from the `past` reports sent before it, oldest first. Nil is returned if
+// there are no past reports, or the environment hasn't completed, as there's no trend to show
+func TrendEnvironment(reportDate string, past []PastReport, env ReportEnvironment, days int) *EnvironmentTrend {
+	date, err := time.Parse(DateFormat, reportDate)
+	if err != nil || days <= 0 || len(past) == 0 || env.Status != Completed {
+		return nil
+	}
+
+	reported := map[string]TrendDay{}
+	for _, pastReport := range past {
+		if trendDay, ok := pastReport.trendDay(env.Name); ok {
+			reported[pastReport.Date] = trendDay
+		}
+	}
+	reported[reportDate] = TrendDay{Date: reportDate, Errors: env.Errors(), Health: env.Health(), Reported: true}
+
+	trend := &EnvironmentTrend{}
+
+	for day := days - 1; day >= 0; day-- {
+		dayDate := date.AddDate(0, 0, -day).Format(DateFormat)
+		trendDay, ok := reported[dayDate]
+		if !ok {
+			trendDay = TrendDay{Date: dayDate}
+		}
+		trend.Days = append(trend.Days, trendDay)
+	}
+
+	for i := len(past) - 1; i >= 0; i-- {
+		pastDate, err := time.Parse(DateFormat, past[i].Date)
+		if err != nil || !pastDate.Before(date) {
+			continue
+		}
+		if errors, ok := past[i].Errors[env.Name]; ok {
+			trend.PreviousDate = past[i].Date
+			trend.PreviousErrors = errors
+			break
+		}
+	}
+
+	for day := 0; ; day++ {
+		trendDay, ok := reported[date.AddDate(0, 0, -day).Format(DateFormat)]
+		if !ok || trendDay.Health != env.Health() {
+			break
+		}
+		trend.Streak++
+	}
+
+	return trend
+}
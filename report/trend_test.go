@@ -0,0 +1,94 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failing builds a completed environment with `errors` failures
+func failing(name string, errors int) ReportEnvironment {
+	return ReportEnvironment{Name: name, Status: Completed, Namespaces: []Namespace{
+		{Name: "ns1", Sections: []Section{{Name: "Failed Pods", Failures: make([]Failure, errors)}}},
+	}}
+}
+
+func TestTrendEnvironment(t *testing.T) {
+	assert := assert.New(t)
+
+	past := []PastReport{
+		{Date: "16-09-2023", Errors: map[string]int{"dev1": 1}},
+		{Date: "18-09-2023", Errors: map[string]int{"dev1": 0}},
+		{Date: "19-09-2023", Errors: map[string]int{"dev1": 2}},
+		{Date: "20-09-2023", Errors: map[string]int{"dev1": 3, "dev2": 0}},
+	}
+
+	assert.Equal(&EnvironmentTrend{
+		PreviousDate:   "20-09-2023",
+		PreviousErrors: 3,
+		Streak:         3,
+		Days: []TrendDay{
+			{Date: "17-09-2023"},
+			{Date: "18-09-2023", Errors: 0, Health: Healthy, Reported: true},
+			{Date: "19-09-2023", Errors: 2, Health: Unhealthy, Reported: true},
+			{Date: "20-09-2023", Errors: 3, Health: Unhealthy, Reported: true},
+			{Date: "21-09-2023", Errors: 5, Health: Unhealthy, Reported: true},
+		},
+	}, TrendEnvironment("21-09-2023", past, failing("dev1", 5), 5))
+
+	// dev3 has no past reports, so only today is known
+	assert.Equal(&EnvironmentTrend{
+		Streak: 1,
+		Days: []TrendDay{
+			{Date: "20-09-2023"},
+			{Date: "21-09-2023", Errors: 0, Health: Healthy, Reported: true},
+		},
+	}, TrendEnvironment("21-09-2023", past, failing("dev3", 0), 2))
+}
+
+func TestTrendEnvironmentTracksDegradedDays(t *testing.T) {
+	assert := assert.New(t)
+
+	degraded := ReportEnvironment{Name: "dev1", Status: Completed, Namespaces: []Namespace{
+		{Name: "ns1", Sections: []Section{{Name: "Failed Pods", Failures: []Failure{{Id: "foo", Severity: SeverityWarning}}}}},
+	}}
+	past := []PastReport{
+		{Date: "18-09-2023", Errors: map[string]int{"dev1": 0}},
+		{Date: "19-09-2023", Errors: map[string]int{"dev1": 0}, Health: map[string]Health{"dev1": Healthy}},
+		{Date: "20-09-2023", Errors: map[string]int{"dev1": 0}, Health: map[string]Health{"dev1": Degraded}},
+	}
+
+	trend := TrendEnvironment("21-09-2023", past, degraded, 4)
+
+	// Without a recorded health, a day without errors is assumed to be healthy
+	assert.Equal([]Health{Healthy, Healthy, Degraded, Degraded}, []Health{
+		trend.Days[0].Health, trend.Days[1].Health, trend.Days[2].Health, trend.Days[3].Health,
+	})
+	// Degraded days don't continue a healthy streak
+	assert.Equal(2, trend.Streak)
+	assert.Equal(1, TrendEnvironment("21-09-2023", past, failing("dev1", 0), 4).Streak)
+}
+
+func TestTrendEnvironmentSkipsGaps(t *testing.T) {
+	past := []PastReport{
+		{Date: "18-09-2023", Errors: map[string]int{"dev1": 2}},
+		{Date: "19-09-2023", Errors: map[string]int{}},
+		{Date: "20-09-2023", Errors: map[string]int{"dev1": 1}},
+	}
+
+	trend := TrendEnvironment("21-09-2023", past, failing("dev1", 1), 7)
+
+	// The streak is broken by the day dev1 wasn't reported
+	assert.Equal(t, 2, trend.Streak)
+	assert.Equal(t, "20-09-2023", trend.PreviousDate)
+}
+
+func TestTrendEnvironmentWithoutTrend(t *testing.T) {
+	assert := assert.New(t)
+
+	past := []PastReport{{Date: "20-09-2023", Errors: map[string]int{"dev1": 1}}}
+
+	assert.Nil(TrendEnvironment("21-09-2023", nil, failing("dev1", 1), 7))
+	assert.Nil(TrendEnvironment("21-09-2023", past, failing("dev1", 1), 0))
+	assert.Nil(TrendEnvironment("21-09-2023", past, ReportEnvironment{Name: "dev1", Status: Pending}, 7))
+}
@@ -0,0 +1,296 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError is a problem with the report at the JSON `Path`, eg.
+// `$.environments[0].name`
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func validationErrorf(path string, format string, args ...any) error {
+	return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validate strictly decodes & validates a report, returning every problem found. The report
+// is only returned if it could be decoded
+func Validate(data []byte) (*ReportJson, []error) {
+	report, errs := decodeLenient(data)
+	if report == nil {
+		return nil, errs
+	}
+
+	// Values that couldn't be decoded are left empty, so only report problems elsewhere
+	for _, err := range report.ValidateReport() {
+		if !coveredBy(err, errs) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return report, nil
+}
+
+// decode strictly decodes a report, only returning it if there were no errors
+func decode(data []byte) (*ReportJson, []error) {
+	report, errs := decodeLenient(data)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return report, nil
+}
+
+// decodeLenient checks the structure of the raw JSON against the report types, so that
+// every unknown field, missing field or wrongly typed value is reported with its path rather
+// than only the first. As much of the report as possible is still decoded, unless the JSON
+// itself is invalid
+func decodeLenient(data []byte) (*ReportJson, []error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, []error{fmt.Errorf("invalid JSON at offset %d: %v", syntaxErr.Offset, err)}
+		}
+		return nil, []error{fmt.Errorf("invalid JSON: %v", err)}
+	}
+
+	errs := checkJson(reflect.TypeOf(ReportJson{}), raw, "$")
+
+	// Type errors have already been found above, and don't stop the rest being decoded
+	report := ReportJson{}
+	if err := json.Unmarshal(data, &report); err != nil && len(errs) == 0 {
+		errs = append(errs, err)
+	}
+
+	return &report, errs
+}
+
+// coveredBy returns whether `err` is at or within the path of one of `errs`
+func coveredBy(err error, errs []error) bool {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	for _, other := range errs {
+		var otherErr *ValidationError
+		if !errors.As(other, &otherErr) {
+			continue
+		}
+
+		path := otherErr.Path
+		if validationErr.Path == path ||
+			strings.HasPrefix(validationErr.Path, path+".") ||
+			strings.HasPrefix(validationErr.Path, path+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+)
+
+// stringOrObjectTypes are struct types that can also be given as a string, as their
+// shorthand
+var stringOrObjectTypes = map[reflect.Type]bool{
+	reflect.TypeOf(Failure{}): true,
+}
+
+// jsonField is a struct field as it appears in the JSON report
+type jsonField struct {
+	Name     string
+	Type     reflect.Type
+	Required bool
+	NonEmpty bool
+}
+
+// jsonFields lists the JSON fields of struct type `t`, in declaration order. Besides the
+// `json` tag, fields may have a `jsonschema` tag of `required` (the field must be present)
+// and/or `nonempty` (strings and arrays must not be empty)
+func jsonFields(t reflect.Type) []jsonField {
+	fields := []jsonField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := jsonField{Name: name, Type: f.Type}
+		for _, opt := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+			switch opt {
+			case "required":
+				field.Required = true
+			case "nonempty":
+				field.NonEmpty = true
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// checkJson checks the decoded JSON `value` at `path` can be decoded into type `t`
+func checkJson(t reflect.Type, value any, path string) []error {
+	if stringOrObjectTypes[t] {
+		switch obj := value.(type) {
+		case string:
+			return nil
+		case map[string]any:
+			return checkStruct(t, obj, path)
+		default:
+			return []error{typeError(path, "string or object", value)}
+		}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if value == nil {
+			return nil
+		}
+		return checkJson(t.Elem(), value, path)
+	}
+
+	// Timestamps are RFC 3339 strings
+	if t == timeType {
+		timestamp, ok := value.(string)
+		if !ok {
+			return []error{typeError(path, "string", value)}
+		}
+		if _, err := time.Parse(time.RFC3339, timestamp); err != nil {
+			return []error{validationErrorf(path, "invalid timestamp '%s', expected RFC 3339 eg. '2023-09-20T09:00:00Z'", timestamp)}
+		}
+		return nil
+	}
+
+	// Types with their own decoding are left to check themselves
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	errs := []error{}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, typeError(path, "object", value))
+		}
+		errs = append(errs, checkStruct(t, obj, path)...)
+
+	case reflect.Slice:
+		if value == nil {
+			return nil
+		}
+
+		arr, ok := value.([]any)
+		if !ok {
+			return append(errs, typeError(path, "array", value))
+		}
+
+		for i, item := range arr {
+			errs = append(errs, checkJson(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			errs = append(errs, typeError(path, "string", value))
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, typeError(path, "boolean", value))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, typeError(path, "number", value))
+		}
+	}
+
+	return errs
+}
+
+// checkStruct checks the fields of the decoded JSON object `obj` at `path` against struct
+// type `t`
+func checkStruct(t reflect.Type, obj map[string]any, path string) []error {
+	errs := []error{}
+
+	fields := map[string]jsonField{}
+	for _, field := range jsonFields(t) {
+		fields[field.Name] = field
+
+		if _, ok := obj[field.Name]; !ok && field.Required {
+			errs = append(errs, validationErrorf(path+"."+field.Name, "required field is missing"))
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			errs = append(errs, validationErrorf(path+"."+key, "unknown field"))
+			continue
+		}
+		errs = append(errs, checkJson(field.Type, obj[key], path+"."+key)...)
+	}
+
+	return errs
+}
+
+func typeError(path string, expected string, value any) error {
+	return validationErrorf(path, "expected %s, got %s", expected, jsonTypeName(value))
+}
+
+// jsonTypeName names the JSON type of a value decoded into `any`
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
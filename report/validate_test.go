@@ -0,0 +1,123 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validationMessages(errs []error) []string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestValidateExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("failed to find examples: %v", err)
+	}
+
+	for _, file := range files {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read '%s'", file)
+		}
+
+		report, errs := Validate(bytes)
+		assert.Empty(t, validationMessages(errs), file)
+		assert.NotNil(t, report, file)
+	}
+}
+
+func TestValidateRejectsUnknownFields(t *testing.T) {
+	report, errs := Validate([]byte(`{
+		"metadata": {"title": "Bring-up", "branch": "main"},
+		"environments": [
+			{"name": "dev1", "status": "completed", "colour": "red", "namespaces": []}
+		]
+	}`))
+
+	assert.Nil(t, report)
+	assert.Equal(t, []string{
+		"$.environments[0].colour: unknown field",
+		"$.metadata.branch: unknown field",
+	}, validationMessages(errs))
+}
+
+func TestValidateReportsAllErrorsWithPaths(t *testing.T) {
+	report, errs := Validate([]byte(`{
+		"environments": [
+			{"name": "dev1", "status": "done", "namespaces": [
+				{"name": "ns1", "sections": [{"name": "Failed Pods", "failures": ["a"]}]},
+				{"name": "ns1", "sections": []},
+				{"name": "ns2"}
+			]},
+			{"name": "dev1", "status": "pending"},
+			{"name": 2, "status": "completed", "namespaces": {}},
+			{"status": "completed"}
+		]
+	}`))
+
+	assert.Nil(t, report)
+	assert.Equal(t, []string{
+		"$.environments[0].namespaces[2].sections: required field is missing",
+		"$.environments[2].name: expected string, got number",
+		"$.environments[2].namespaces: expected array, got object",
+		"$.environments[3].name: required field is missing",
+		"$.environments[0].status: unknown status 'done', expected one of 'pending', 'running', 'completed', 'errored', 'skipped'",
+		"$.environments[0].namespaces[1].name: duplicate namespace 'ns1', also at $.environments[0].namespaces[0]",
+		"$.environments[0].namespaces[1].sections: namespace has no sections",
+		"$.environments[1].name: duplicate environment 'dev1', also at $.environments[0]",
+	}, validationMessages(errs))
+}
+
+func TestValidateChecksTimestamps(t *testing.T) {
+	report, errs := Validate([]byte(`{
+		"environments": [
+			{"name": "dev1", "status": "errored", "error": "boom", "log_url": "https://jenkins/1/console",
+				"started_at": "2023-09-20T09:00:00Z", "finished_at": "2023-09-20T09:12:30Z"},
+			{"name": "dev2", "status": "running", "started_at": "20-09-2023"},
+			{"name": "dev3", "status": "completed", "namespaces": [],
+				"started_at": "2023-09-20T09:00:00Z", "finished_at": "2023-09-20T08:00:00Z"}
+		]
+	}`))
+
+	assert.Nil(t, report)
+	assert.Equal(t, []string{
+		"$.environments[1].started_at: invalid timestamp '20-09-2023', expected RFC 3339 eg. '2023-09-20T09:00:00Z'",
+		"$.environments[2].finished_at: finished before it started at 2023-09-20T09:00:00Z",
+	}, validationMessages(errs))
+}
+
+func TestValidateRejectsInvalidJson(t *testing.T) {
+	report, errs := Validate([]byte(`{"environments": [}`))
+
+	assert.Nil(t, report)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorContains(t, errs[0], "invalid JSON at offset 19")
+	}
+}
+
+func TestFromJsonIsStrict(t *testing.T) {
+	_, err := FromJson([]byte(`{"environments": [], "extra": true}`))
+	assert.EqualError(t, err, "$.extra: unknown field")
+
+	_, err = FromJson([]byte(`{"metadata": {}}`))
+	assert.EqualError(t, err, "$.environments: required field is missing")
+}
+
+func TestPublishedSchemaIsUpToDate(t *testing.T) {
+	published, err := os.ReadFile("../schema/report.schema.json")
+	if err != nil {
+		t.Fatalf("failed to read published schema: %v", err)
+	}
+
+	generated, err := MarshalJsonSchema()
+	assert.NoError(t, err)
+	assert.Equal(t, string(generated), string(published), "regenerate with `go run . schema > schema/report.schema.json`")
+}
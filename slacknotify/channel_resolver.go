@@ -0,0 +1,221 @@
+package slacknotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// channelIdPattern matches Slack conversation IDs, eg. `C0123ABCD`
+var channelIdPattern = regexp.MustCompile(`^[CGD][A-Z0-9]{8,}$`)
+
+// IsChannelId returns whether `channel` is a channel ID, rather than a name that must be
+// resolved with `channelResolver.Resolve`
+func IsChannelId(channel string) bool {
+	return channelIdPattern.MatchString(channel)
+}
+
+// ResolveScopes are the OAuth scopes needed to resolve a channel name, as both public &
+// private channels are listed with `conversations.list`, which fails without either
+var ResolveScopes = []string{"channels:read", "groups:read"}
+
+// DefaultChannelCachePath is where resolved channel IDs are cached between runs, or empty
+// if the user cache directory can't be determined
+func DefaultChannelCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "slacker", "channels.json")
+}
+
+// staleChannelErrors mean a cached channel ID no longer refers to a channel the app can post to
+var staleChannelErrors = []string{"channel_not_found", "not_in_channel", "is_archived"}
+
+// channelCache maps the team ID of each workspace to the IDs of its channels, keyed by name
+type channelCache map[string]map[string]string
+
+// channelResolver resolves channel names to the channel IDs required by the Web API
+type channelResolver struct {
+	client      *slack.Client
+	retryConfig RetryConfig
+	cachePath   string
+	teamId      string
+}
+
+func NewChannelResolver(tokenSource TokenSource, options ...slack.Option) (*channelResolver, error) {
+	client, err := newClient(tokenSource, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &channelResolver{
+		client:      client,
+		retryConfig: DefaultRetryConfig(),
+	}, nil
+}
+
+// WithRetry sets how failed Slack API calls are retried
+func (r *channelResolver) WithRetry(retryConfig RetryConfig) *channelResolver {
+	r.retryConfig = retryConfig
+	return r
+}
+
+// WithCache caches resolved channel IDs in the file at `cachePath`, under the workspace
+// `teamId`, eg. from `auth.test`, as channel names are only unique within a workspace. An
+// empty path or team ID disables the cache
+func (r *channelResolver) WithCache(cachePath string, teamId string) *channelResolver {
+	r.cachePath = cachePath
+	r.teamId = teamId
+	if teamId == "" {
+		r.cachePath = ""
+	}
+	return r
+}
+
+// Resolve returns the ID of `channel`, which may be a channel ID (returned unchanged) or a
+// channel name, with or without a leading `#`. Cached IDs are checked with
+// `conversations.info`, and evicted if the channel has since been renamed, archived, deleted
+// or left
+func (r *channelResolver) Resolve(ctx context.Context, channel string) (string, error) {
+	if IsChannelId(channel) {
+		return channel, nil
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(channel, "#"))
+	logger := log.WithField("channel", name)
+
+	cache := r.readCache()
+	if id, ok := cache[r.teamId][name]; ok {
+		valid, err := r.checkChannel(ctx, id, name)
+		if err != nil {
+			return "", err
+		}
+		if valid {
+			logger.Debugf("Using cached channel ID %s", id)
+			return id, nil
+		}
+
+		logger.Debugf("Evicting stale cached channel ID %s", id)
+		delete(cache[r.teamId], name)
+		r.writeCache(cache)
+	}
+
+	logger.Debug("Looking up channel ID")
+
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: true,
+		Limit:           1000,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+
+	for {
+		var (
+			channels   []slack.Channel
+			nextCursor string
+		)
+
+		err := r.retryConfig.retry(ctx, "conversations.list", func() (err error) {
+			channels, nextCursor, err = r.client.GetConversationsContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("error listing channels: %s", err)
+		}
+
+		for _, c := range channels {
+			if c.Name != name {
+				continue
+			}
+
+			if !c.IsMember {
+				return "", fmt.Errorf("not a member of channel #%s (%s) - invite the app to the channel with `/invite`", name, c.ID)
+			}
+
+			logger.Debugf("Resolved channel ID %s", c.ID)
+			if cache[r.teamId] == nil {
+				cache[r.teamId] = map[string]string{}
+			}
+			cache[r.teamId][name] = c.ID
+			r.writeCache(cache)
+
+			return c.ID, nil
+		}
+
+		if nextCursor == "" {
+			return "", fmt.Errorf("channel #%s not found - private channels are only visible once the app has been invited", name)
+		}
+		params.Cursor = nextCursor
+	}
+}
+
+// checkChannel checks that the cached `id` is still channel `name`, and that the app is still
+// a member of it
+func (r *channelResolver) checkChannel(ctx context.Context, id string, name string) (bool, error) {
+	var channel *slack.Channel
+	err := r.retryConfig.retry(ctx, "conversations.info", func() (err error) {
+		channel, err = r.client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: id})
+		return err
+	})
+
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) && slices.Contains(staleChannelErrors, slackErr.Err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking channel %s: %s", id, err)
+	}
+
+	return channel.Name == name && channel.IsMember && !channel.IsArchived, nil
+}
+
+// readCache reads the cached channel IDs. A missing or unreadable cache is treated as empty
+func (r *channelResolver) readCache() channelCache {
+	cache := channelCache{}
+	if r.cachePath == "" {
+		return cache
+	}
+
+	bytes, err := os.ReadFile(r.cachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("failed to read channel cache: %v", err)
+		}
+		return cache
+	}
+
+	if err := json.Unmarshal(bytes, &cache); err != nil {
+		log.Warnf("ignoring invalid channel cache %s: %v", r.cachePath, err)
+		return channelCache{}
+	}
+
+	return cache
+}
+
+// writeCache writes the cached channel IDs. Failures only mean the lookup is repeated next
+// time, so are logged rather than returned
+func (r *channelResolver) writeCache(cache channelCache) {
+	if r.cachePath == "" {
+		return
+	}
+
+	bytes, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(r.cachePath), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(r.cachePath, bytes, 0o644)
+	}
+	if err != nil {
+		log.Warnf("failed to write channel cache: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package slacknotify
+
+import (
+	"fmt"
+	"strings"
+
+	"dsab.slacker/report"
+)
+
+// buildDiffSummary counts the new, persistent & resolved failures since the previous report,
+// to be appended to a health message. It is empty when there's nothing to compare, or no
+// failures in either report
+func buildDiffSummary(diff *report.EnvironmentDiff) string {
+	if diff == nil {
+		return ""
+	}
+
+	parts := []string{}
+	if diff.New > 0 {
+		parts = append(parts, fmt.Sprintf(":new: %d new", diff.New))
+	}
+	if diff.Persistent > 0 {
+		parts = append(parts, fmt.Sprintf(":repeat: %d persistent", diff.Persistent))
+	}
+	if diff.Resolved > 0 {
+		parts = append(parts, fmt.Sprintf(":white_check_mark: %d resolved", diff.Resolved))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return " · " + strings.Join(parts, " · ")
+}
+
+// buildFailureDiffLine marks a failure as new or resolved. Persistent failures are left as-is
+func buildFailureDiffLine(failure report.FailureDiff) string {
+	line := buildFailureLine(failure.Failure)
+
+	switch failure.Change {
+	case report.ChangeNew:
+		return ":new: " + line
+	case report.ChangeResolved:
+		return fmt.Sprintf(":white_check_mark: ~%s~", line)
+	default:
+		return line
+	}
+}
+
+// diffNamespaces renders the namespaces of the diff, with each failure marked by how it has
+// changed, followed by the resolved failures of each section
+func diffNamespaces(diff *report.EnvironmentDiff) []namespaceLines {
+	namespaces := []namespaceLines{}
+
+	for _, nsDiff := range diff.Namespaces {
+		ns := namespaceLines{Name: nsDiff.Name}
+
+		for _, sectionDiff := range nsDiff.Sections {
+			var current, resolved []string
+			for _, failure := range sectionDiff.Failures {
+				if failure.Change == report.ChangeResolved {
+					resolved = append(resolved, buildFailureDiffLine(failure))
+				} else {
+					current = append(current, buildFailureDiffLine(failure))
+				}
+			}
+
+			ns.Sections = append(ns.Sections, sectionLines{
+				Section:  report.Section{Icon: sectionDiff.Icon, Name: sectionDiff.Name, Threshold: sectionDiff.Threshold},
+				Lines:    append(current, resolved...),
+				resolved: len(resolved),
+			})
+		}
+
+		namespaces = append(namespaces, ns)
+	}
+
+	return namespaces
+}
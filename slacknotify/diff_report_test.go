@@ -0,0 +1,68 @@
+package slacknotify
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+func TestBuildDiffSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", buildDiffSummary(nil))
+	assert.Equal("", buildDiffSummary(&report.EnvironmentDiff{}))
+	assert.Equal(" · :new: 2 new · :repeat: 3 persistent · :white_check_mark: 1 resolved",
+		buildDiffSummary(&report.EnvironmentDiff{New: 2, Persistent: 3, Resolved: 1}))
+	assert.Equal(" · :white_check_mark: 4 resolved", buildDiffSummary(&report.EnvironmentDiff{Resolved: 4}))
+}
+
+func TestBuildEnvironmentReportMarksChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo", "bar")}}},
+	}}
+	previous := &report.ReportJson{Environments: []report.ReportEnvironment{
+		{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo", "baz")}}},
+		}},
+	}}
+
+	attachments := renderEnvironmentReport(t, env, report.DiffEnvironment(previous, env), 0)
+
+	assert.Equal(":rotating_light: Unhealthy - 2 issues · :new: 1 new · :repeat: 1 persistent · :white_check_mark: 1 resolved", attachments[0].Text)
+
+	section := attachments[1].Blocks.BlockSet[3].(*slack.SectionBlock)
+	assert.Equal("foo\n:new: bar\n:white_check_mark: ~baz~", section.Text.Text)
+}
+
+func TestBuildEnvironmentReportDoesNotCountResolvedWhenTruncating(t *testing.T) {
+	assert := assert.New(t)
+
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo")}}},
+		{Name: "ns2", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("bar", "baz")}}},
+	}}
+	previous := &report.ReportJson{Environments: []report.ReportEnvironment{
+		{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo", "qux", "quux")}}},
+			{Name: "ns2", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("corge")}}},
+		}},
+	}}
+
+	attachments := renderEnvironmentReport(t, env, report.DiffEnvironment(previous, env), 2)
+	assert.Len(attachments, 4)
+
+	section := attachments[1].Blocks.BlockSet[3].(*slack.SectionBlock)
+	assert.Equal("foo\n:white_check_mark: ~qux~\n:white_check_mark: ~quux~", section.Text.Text)
+
+	// The second namespace's resolved failure is still shown, as it was reached
+	section = attachments[2].Blocks.BlockSet[3].(*slack.SectionBlock)
+	assert.Equal(":new: bar\n:white_check_mark: ~corge~", section.Text.Text)
+
+	note := attachments[3].Blocks.BlockSet[0].(*slack.ContextBlock)
+	assert.Equal(":page_facing_up: Showing the first 2 of 3 failures - see the attached files for the full report", note.ContextElements.Elements[0].(*slack.TextBlockObject).Text)
+}
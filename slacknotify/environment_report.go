@@ -1,8 +1,9 @@
 package slacknotify
 
 import (
+	"encoding/json"
 	"fmt"
-	"strings"
+	"slices"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
@@ -10,87 +11,173 @@ import (
 	"dsab.slacker/report"
 )
 
-func buildNamespaceReportHeader(ns report.Namespace) slack.Block {
-	text := fmt.Sprintf("*Namespace:* %s", ns.Name)
+// severityIcons mark failures with an explicit severity. Failures without one are shown as
+// they always have been
+var severityIcons = map[report.Severity]string{
+	report.SeverityCritical: ":bangbang:",
+	report.SeverityError:    ":x:",
+	report.SeverityWarning:  ":warning:",
+}
 
-	return slack.NewSectionBlock(
-		nil,
-		[]*slack.TextBlockObject{markdown(text)},
-		nil,
-	)
+// namespaceLines is a namespace with each of its failures rendered as a line of text, and the
+// mentions of the owners of its failing items
+type namespaceLines struct {
+	Name     string
+	Sections []sectionLines
+	Mentions []string
+}
+
+type sectionLines struct {
+	Section report.Section
+	Lines   []string
+
+	// resolved is the number of lines at the end of `Lines` that are resolved failures,
+	// which aren't counted when truncating
+	resolved int
 }
 
-// buildEnvironmentHealthMessage builds the health message used in replies, for each environment
-func buildEnvironmentHealthMessage(env report.ReportEnvironment) string {
-	if env.IsHealthy() {
-		return fmt.Sprintf(":white_check_mark: Healthy")
-	} else {
-		return fmt.Sprintf(":rotating_light: Unhealthy - %d issues", env.Errors())
+// renderNamespaces renders each failure of `namespaces` as a line of text
+func renderNamespaces(namespaces []report.Namespace) []namespaceLines {
+	rendered := []namespaceLines{}
+
+	for _, ns := range namespaces {
+		nsLines := namespaceLines{Name: ns.Name}
+
+		for _, section := range ns.Sections {
+			lines := make([]string, len(section.Failures))
+			for i, failure := range section.Failures {
+				lines[i] = buildFailureLine(failure)
+			}
+			nsLines.Sections = append(nsLines.Sections, sectionLines{Section: section, Lines: lines})
+		}
+
+		rendered = append(rendered, nsLines)
 	}
+
+	return rendered
 }
 
-func buildSectionReport(s report.Section) []slack.Block {
-	var (
-		header = fmt.Sprintf("%s %s", s.Icon, s.Name)
-		items  = strings.Join(s.Failures, "\n")
-	)
+// buildFailureLine renders a failure as its ID, linked to its URL, preceded by its severity
+// icon and followed by its message, labels & owner when given
+func buildFailureLine(failure report.Failure) string {
+	line := failure.Id
+	if failure.Url != "" {
+		line = fmt.Sprintf("<%s|%s>", failure.Url, failure.Id)
+	}
 
-	if len(s.Failures) == 0 {
-		return []slack.Block{}
+	if icon, ok := severityIcons[failure.Severity]; ok {
+		line = icon + " " + line
 	}
 
-	return []slack.Block{
-		slack.NewContextBlock("", markdown(header)),
-		slack.NewDividerBlock(),
-		slack.NewSectionBlock(markdown(items), nil, nil),
+	if failure.Message != "" {
+		line += " - " + failure.Message
+	}
+
+	for _, label := range failure.Labels {
+		line += fmt.Sprintf(" `%s`", label)
 	}
-}
 
-func buildEnvironmentReportHeader(env report.ReportEnvironment) slack.Attachment {
-	return slack.Attachment{
-		Color:         attachmentColour(env),
-		AuthorName:    "Environment",
-		AuthorSubname: env.Name,
-		Text:          buildEnvironmentHealthMessage(env),
+	if failure.Owner != "" {
+		line += fmt.Sprintf(" _(%s)_", failure.Owner)
 	}
+
+	return line
 }
 
-func buildEnvironmentReport(env report.ReportEnvironment) []slack.Attachment {
-	var (
-		attachmentColor = attachmentColour(env)
-		attachments     = []slack.Attachment{}
-	)
+// environmentTemplateData is the data `EnvironmentTemplate` is rendered with. `Namespaces`
+// have each failure rendered as a line, marked by how it's changed when `Diff` is given,
+// and are cut short at `MaxFailures` failures when `Truncated`. `Mentions` are the owners of
+// the environment's failing items
+type environmentTemplateData struct {
+	Environment report.ReportEnvironment
+	Diff        *report.EnvironmentDiff
+	Namespaces  []namespaceLines
+	Truncated   bool
+	MaxFailures int
+	Mentions    []string
+}
+
+// buildEnvironmentReport renders the attachments for an environment reply. When `diff` is
+// given, failures are marked as new or resolved since the previous report. When
+// `maxFailures` is greater than zero, only the first `maxFailures` failures are shown,
+// followed by a note that the full report has been attached. Each namespace is followed by
+// the `mentions` of the owners of its failing items, when given
+func buildEnvironmentReport(templates *Templates, env report.ReportEnvironment, diff *report.EnvironmentDiff, maxFailures int, mentions *environmentMentions) ([]slack.Attachment, error) {
+	data := &environmentTemplateData{
+		Environment: env,
+		Diff:        diff,
+		Namespaces:  renderNamespaces(env.Namespaces),
+		Truncated:   maxFailures > 0 && env.Failures() > maxFailures,
+		MaxFailures: maxFailures,
+	}
+
+	if diff != nil {
+		data.Namespaces = diffNamespaces(diff)
+	}
+	if data.Truncated {
+		data.Namespaces = truncateNamespaces(data.Namespaces, maxFailures)
+	}
+	if mentions != nil {
+		data.Mentions = mentions.All
+		for i, ns := range data.Namespaces {
+			data.Namespaces[i].Mentions = mentions.Namespace(ns.Name)
+		}
+	}
 
-	attachments = append(attachments, buildEnvironmentReportHeader(env))
+	log.WithField("environment", env.Name).Debugf("Rendering %d namespaces", len(data.Namespaces))
 
-	if env.Status != report.Completed {
-		return attachments
+	rendered, err := templates.execute(EnvironmentTemplate, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not render environment report for %s: %v", env.Name, err)
 	}
 
-	logger := log.WithField("environment", env.Name)
-	logger.Debugf("Generating blocks for %d namespaces", len(env.Namespaces))
+	attachments := []slack.Attachment{}
+	if err := json.Unmarshal(rendered, &attachments); err != nil {
+		return nil, fmt.Errorf("environment report template did not produce a JSON array of attachments: %v", err)
+	}
 
-	for _, ns := range env.Namespaces {
-		blocks := []slack.Block{}
+	return attachments, nil
+}
 
-		blocks = append(blocks, buildNamespaceReportHeader(ns))
+// truncateNamespaces keeps only the first `maxFailures` failures of `namespaces`, along with
+// the resolved failures of each section reached. Namespaces after the last failure shown are
+// dropped entirely
+func truncateNamespaces(namespaces []namespaceLines, maxFailures int) []namespaceLines {
+	var (
+		truncated = []namespaceLines{}
+		remaining = maxFailures
+	)
 
-		logger = logger.WithField("namespace", ns.Name)
-		logger.Debugf("Generating blocks for %d sections", len(ns.Sections))
+	for _, ns := range namespaces {
+		if remaining == 0 {
+			break
+		}
 
+		nsLines := namespaceLines{Name: ns.Name}
 		for _, section := range ns.Sections {
-			logger = logger.WithField("section", section.Name)
-			logger.Debugf("Generating blocks for %d failures", len(section.Failures))
-			blocks = append(blocks, buildSectionReport(section)...)
+			var (
+				reached  = remaining > 0
+				current  = section.Lines[:len(section.Lines)-section.resolved]
+				resolved = section.Lines[len(current):]
+			)
+
+			if len(current) > remaining {
+				current = current[:remaining]
+			}
+			remaining -= len(current)
+
+			lines := slices.Clip(current)
+			if reached {
+				lines = append(lines, resolved...)
+			} else {
+				resolved = nil
+			}
+
+			nsLines.Sections = append(nsLines.Sections, sectionLines{Section: section.Section, Lines: lines, resolved: len(resolved)})
 		}
 
-		attachments = append(attachments, slack.Attachment{
-			Color: attachmentColor,
-			Blocks: slack.Blocks{
-				BlockSet: blocks,
-			},
-		})
+		truncated = append(truncated, nsLines)
 	}
 
-	return attachments
+	return truncated
 }
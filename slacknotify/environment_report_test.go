@@ -0,0 +1,104 @@
+package slacknotify
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+func TestBuildFailureLine(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("foo", buildFailureLine(report.Failure{Id: "foo"}))
+	assert.Equal(":bangbang: <https://logs/foo|foo> - CrashLoopBackOff `restarts` `oom` _(team-a)_", buildFailureLine(report.Failure{
+		Id:       "foo",
+		Message:  "CrashLoopBackOff",
+		Severity: report.SeverityCritical,
+		Url:      "https://logs/foo",
+		Owner:    "team-a",
+		Labels:   []string{"restarts", "oom"},
+	}))
+	assert.Equal(":warning: bar - Slow to start", buildFailureLine(report.Failure{
+		Id: "bar", Message: "Slow to start", Severity: report.SeverityWarning,
+	}))
+}
+
+func TestBuildEnvironmentReportShowsStructuredFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: []report.Failure{
+			{Id: "foo", Severity: report.SeverityError, Url: "https://logs/foo"},
+			{Id: "bar", Severity: report.SeverityWarning},
+		}}}},
+	}}
+	previous := &report.ReportJson{Environments: []report.ReportEnvironment{
+		{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo")}}},
+		}},
+	}}
+
+	// Warnings are counted separately from errors
+	attachments := renderEnvironmentReport(t, env, nil, 0)
+	assert.Equal(":rotating_light: Unhealthy - 1 issues, 1 warnings", attachments[0].Text)
+
+	section := attachments[1].Blocks.BlockSet[3].(*slack.SectionBlock)
+	assert.Equal(":x: <https://logs/foo|foo>\n:warning: bar", section.Text.Text)
+
+	// Failures are matched with the previous report by ID
+	attachments = renderEnvironmentReport(t, env, report.DiffEnvironment(previous, env), 0)
+	section = attachments[1].Blocks.BlockSet[3].(*slack.SectionBlock)
+	assert.Equal(":x: <https://logs/foo|foo>\n:new: :warning: bar", section.Text.Text)
+}
+
+func TestRenderFailureMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("foo", renderFailureMarkdown(report.Failure{Id: "foo"}))
+	assert.Equal("[api-0](https://logs) (warning): Restarted 3 times - owner: team-a, labels: flaky, slow",
+		renderFailureMarkdown(report.Failure{
+			Id:       "api-0",
+			Url:      "https://logs",
+			Severity: report.SeverityWarning,
+			Message:  "Restarted 3 times",
+			Owner:    "team-a",
+			Labels:   []string{"flaky", "slow"},
+		}))
+}
+
+func TestHealthMessagesAndColours(t *testing.T) {
+	assert := assert.New(t)
+
+	env := func(failures ...report.Failure) report.ReportEnvironment {
+		return report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Threshold: 1, Failures: failures}}},
+		}}
+	}
+
+	healthy := env()
+	assert.Equal(":white_check_mark: *dev1* | Healthy", renderSummaryLine(t, healthy, nil, nil))
+	assert.Equal("#00FF00", attachmentColour(healthy))
+
+	degraded := env(report.Failure{Id: "a"})
+	assert.Equal(":warning: *dev1* | Degraded - 1 warnings", renderSummaryLine(t, degraded, nil, nil))
+	assert.Equal(":warning: Degraded - 1 warnings", renderTemplate(t, "environmentHealth", &environmentTemplateData{Environment: degraded}))
+	assert.Equal("#FFBF00", attachmentColour(degraded))
+
+	unhealthy := env(report.Failure{Id: "a"}, report.Failure{Id: "b", Severity: report.SeverityWarning})
+	assert.Equal(":rotating_light: *dev1* | Unhealthy - 1 issues, 1 warnings", renderSummaryLine(t, unhealthy, nil, nil))
+	assert.Equal("#FF0000", attachmentColour(unhealthy))
+}
+
+func TestBuildEnvironmentReportShowsThreshold(t *testing.T) {
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Icon: ":whale:", Name: "Failed Pods", Threshold: 2, Failures: report.NewFailures("a")}}},
+	}}
+
+	attachments := renderEnvironmentReport(t, env, nil, 0)
+
+	header := attachments[1].Blocks.BlockSet[1].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject)
+	assert.Equal(t, ":whale: Failed Pods _(up to 2 allowed)_", header.Text)
+}
@@ -0,0 +1,149 @@
+package slacknotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	"dsab.slacker/report"
+)
+
+// environmentReportFilename is the name of an uploaded environment report file. Names are
+// stable so that previous uploads can be found & replaced on later runs
+func environmentReportFilename(env report.ReportEnvironment, extension string) string {
+	return fmt.Sprintf("%s-report.%s", env.Name, extension)
+}
+
+// renderEnvironmentJson renders the complete environment report as indented JSON
+func renderEnvironmentJson(env report.ReportEnvironment) (string, error) {
+	bytes, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// renderEnvironmentMarkdown renders the complete environment report as a human-readable
+// Markdown document
+func renderEnvironmentMarkdown(env report.ReportEnvironment) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# Environment: %s\n\n", env.Name)
+	fmt.Fprintf(&sb, "- Status: %s\n", env.Status)
+	fmt.Fprintf(&sb, "- Failures: %d\n", env.Failures())
+
+	for _, ns := range env.Namespaces {
+		fmt.Fprintf(&sb, "\n## Namespace: %s\n", ns.Name)
+
+		for _, s := range ns.Sections {
+			if len(s.Failures) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(&sb, "\n### %s (%d)\n\n", s.Name, len(s.Failures))
+			for _, failure := range s.Failures {
+				fmt.Fprintf(&sb, "- %s\n", renderFailureMarkdown(failure))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// renderFailureMarkdown renders a failure as a Markdown list item, eg.
+// `[api-0](https://logs) (warning): Restarted 3 times - owner: team-a, labels: flaky`
+func renderFailureMarkdown(failure report.Failure) string {
+	line := failure.Id
+	if failure.Url != "" {
+		line = fmt.Sprintf("[%s](%s)", failure.Id, failure.Url)
+	}
+
+	if failure.Severity != "" {
+		line += fmt.Sprintf(" (%s)", failure.Severity)
+	}
+
+	if failure.Message != "" {
+		line += ": " + failure.Message
+	}
+
+	details := []string{}
+	if failure.Owner != "" {
+		details = append(details, "owner: "+failure.Owner)
+	}
+	if len(failure.Labels) > 0 {
+		details = append(details, "labels: "+strings.Join(failure.Labels, ", "))
+	}
+	if len(details) > 0 {
+		line += " - " + strings.Join(details, ", ")
+	}
+
+	return line
+}
+
+// uploadEnvironmentReport uploads the complete environment report, as both JSON & Markdown,
+// into the thread of `parentMessageTs`
+func (c *slackNotifierConfig) uploadEnvironmentReport(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment) error {
+	jsonContent, err := renderEnvironmentJson(env)
+	if err != nil {
+		return fmt.Errorf("failed to render environment report: %v", err)
+	}
+
+	files := []struct {
+		extension string
+		title     string
+		content   string
+	}{
+		{"md", fmt.Sprintf("Full report for %s", env.Name), renderEnvironmentMarkdown(env)},
+		{"json", fmt.Sprintf("Full report for %s (JSON)", env.Name), jsonContent},
+	}
+
+	for _, file := range files {
+		filename := environmentReportFilename(env, file.extension)
+		log.WithField("env", env.Name).Debugf("Uploading %s", filename)
+
+		err := c.retryConfig.retryPost(ctx, "files.upload", func() error {
+			_, err := c.client.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+				Channel:         c.channel,
+				ThreadTimestamp: parentMessageTs.Ts,
+				Filename:        filename,
+				Title:           file.title,
+				Content:         file.content,
+				FileSize:        len(file.content),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %v", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteEnvironmentReportUploads deletes any report files previously uploaded for `env` into
+// the thread of `parentMessageTs`
+func (c *slackNotifierConfig) deleteEnvironmentReportUploads(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment) error {
+	fileIds, err := c.finder.findUploadedFiles(ctx, parentMessageTs,
+		environmentReportFilename(env, "md"),
+		environmentReportFilename(env, "json"),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, fileId := range fileIds {
+		log.WithField("env", env.Name).Debugf("Deleting previous upload %s", fileId)
+		err := c.retryConfig.retry(ctx, "files.delete", func() error {
+			return c.client.DeleteFileContext(ctx, fileId)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete previous upload %s: %v", fileId, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package slacknotify
+
+import (
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Block Kit limits that messages are laid out to fit within
+// See: https://api.slack.com/reference/block-kit/blocks
+const (
+	maxBlocksPerMessage      = 50
+	maxAttachmentsPerMessage = 20
+	maxSectionTextLength     = 3000
+)
+
+// truncateText shortens `text` to at most `maxLength` characters, marking that it
+// has been cut short
+func truncateText(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+
+	return string(runes[:maxLength-1]) + "…"
+}
+
+// chunkLines joins `lines` with newlines into as few chunks as possible, each of which is
+// at most `maxLength` characters. Any single line that is too long is truncated
+func chunkLines(lines []string, maxLength int) []string {
+	var (
+		chunks  []string
+		current []string
+		length  int
+	)
+
+	for _, line := range lines {
+		line = truncateText(line, maxLength)
+		lineLength := len([]rune(line))
+
+		// Account for the newline joining this line to the previous one
+		if len(current) > 0 && length+1+lineLength > maxLength {
+			chunks = append(chunks, strings.Join(current, "\n"))
+			current, length = nil, 0
+		}
+
+		if len(current) > 0 {
+			length++
+		}
+		current = append(current, line)
+		length += lineLength
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, "\n"))
+	}
+
+	return chunks
+}
+
+// paginateBlocks splits `blocks` into pages of at most `maxBlocks` blocks
+func paginateBlocks(blocks []slack.Block, maxBlocks int) [][]slack.Block {
+	var pages [][]slack.Block
+
+	for len(blocks) > maxBlocks {
+		pages = append(pages, blocks[:maxBlocks])
+		blocks = blocks[maxBlocks:]
+	}
+
+	return append(pages, blocks)
+}
+
+// attachmentSize is the number of blocks an attachment contributes to a message. Legacy
+// attachments without blocks are counted as a single block
+func attachmentSize(attachment slack.Attachment) int {
+	if n := len(attachment.Blocks.BlockSet); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// paginateAttachments packs `attachments` into as few messages as possible, keeping the
+// total block & attachment count of each message within the Block Kit limits. Attachments
+// are kept whole where they fit in a message of their own, otherwise their blocks are
+// split across several attachments of the same colour
+func paginateAttachments(attachments []slack.Attachment, maxBlocks int, maxAttachments int) [][]slack.Attachment {
+	var (
+		pages   [][]slack.Attachment
+		current []slack.Attachment
+		size    int
+	)
+
+	flush := func() {
+		pages = append(pages, current)
+		current, size = nil, 0
+	}
+
+	for _, attachment := range attachments {
+		blocks := attachment.Blocks.BlockSet
+
+		if len(blocks) == 0 {
+			if len(current) == maxAttachments || size+1 > maxBlocks {
+				flush()
+			}
+			current = append(current, attachment)
+			size++
+			continue
+		}
+
+		// Only cleared once a piece has been appended, so that an attachment moved whole onto
+		// a new page is kept as-is
+		first := true
+		for len(blocks) > 0 {
+			room := maxBlocks - size
+			if len(current) == maxAttachments || room == 0 ||
+				(len(blocks) > room && len(blocks) <= maxBlocks && size > 0) {
+				flush()
+				continue
+			}
+
+			take := len(blocks)
+			if take > room {
+				take = room
+			}
+
+			piece := slack.Attachment{
+				Color:  attachment.Color,
+				Blocks: slack.Blocks{BlockSet: blocks[:take]},
+			}
+			if first && take == len(blocks) {
+				piece = attachment
+			}
+
+			current = append(current, piece)
+			size += take
+			blocks = blocks[take:]
+			first = false
+		}
+	}
+
+	if len(current) > 0 || len(pages) == 0 {
+		flush()
+	}
+
+	return pages
+}
+
+// layoutSummaryReport splits the summary blocks into the parent message & any continuation
+// messages. The trailing action block is always kept on the parent message
+func layoutSummaryReport(blocks []slack.Block) [][]slack.Block {
+	var actions slack.Block
+
+	if n := len(blocks); n > 0 && blocks[n-1].BlockType() == slack.MBTAction {
+		actions = blocks[n-1]
+		blocks = blocks[:n-1]
+	}
+
+	if actions == nil {
+		return paginateBlocks(blocks, maxBlocksPerMessage)
+	}
+
+	pages := paginateBlocks(blocks, maxBlocksPerMessage-1)
+	pages[0] = append(pages[0][:len(pages[0]):len(pages[0])], actions)
+
+	return pages
+}
+
+// layoutEnvironmentReport splits the environment attachments into the environment reply &
+// any continuation replies
+func layoutEnvironmentReport(attachments []slack.Attachment) [][]slack.Attachment {
+	return paginateAttachments(attachments, maxBlocksPerMessage, maxAttachmentsPerMessage)
+}
@@ -0,0 +1,81 @@
+package slacknotify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkLines(t *testing.T) {
+	assert := assert.New(t)
+
+	lines := []string{"aaaa", "bbbb", "cccc"}
+
+	assert.Equal([]string{"aaaa\nbbbb\ncccc"}, chunkLines(lines, 14))
+	assert.Equal([]string{"aaaa\nbbbb", "cccc"}, chunkLines(lines, 13))
+	assert.Equal([]string{"aa…", "bb…", "cc…"}, chunkLines(lines, 3))
+}
+
+func TestLayoutEnvironmentReportSplitsLargeAttachments(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := make([]slack.Block, 120)
+	for i := range blocks {
+		blocks[i] = slack.NewDividerBlock()
+	}
+
+	pages := layoutEnvironmentReport([]slack.Attachment{
+		{Text: "header"},
+		{Color: "#FF0000", Blocks: slack.Blocks{BlockSet: blocks}},
+	})
+
+	assert.Len(pages, 3)
+	for _, page := range pages {
+		size := 0
+		for _, attachment := range page {
+			size += attachmentSize(attachment)
+			assert.NotEqual("", attachment.Color+attachment.Text)
+		}
+		assert.LessOrEqual(size, maxBlocksPerMessage)
+	}
+}
+
+func TestLayoutEnvironmentReportKeepsAttachmentsMovedToNewPage(t *testing.T) {
+	assert := assert.New(t)
+
+	dividers := func(n int) slack.Blocks {
+		blocks := make([]slack.Block, n)
+		for i := range blocks {
+			blocks[i] = slack.NewDividerBlock()
+		}
+		return slack.Blocks{BlockSet: blocks}
+	}
+
+	moved := slack.Attachment{Color: "#FF0000", Title: "ns2", Text: "text", Blocks: dividers(10)}
+	pages := layoutEnvironmentReport([]slack.Attachment{
+		{Color: "#FF0000", Blocks: dividers(maxBlocksPerMessage - 5)},
+		moved,
+	})
+
+	assert.Len(pages, 2)
+	assert.Equal([]slack.Attachment{moved}, pages[1])
+}
+
+func TestLayoutSummaryReportKeepsActionsOnParent(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := []slack.Block{}
+	for i := 0; i < 60; i++ {
+		blocks = append(blocks, slack.NewSectionBlock(markdown(strings.Repeat("x", i+1)), nil, nil))
+	}
+	blocks = append(blocks, slack.NewActionBlock("", linkButton("Learn more", "https://example.com")))
+
+	pages := layoutSummaryReport(blocks)
+
+	assert.Len(pages, 2)
+	assert.Len(pages[0], maxBlocksPerMessage)
+	assert.Equal(slack.MBTAction, pages[0][len(pages[0])-1].BlockType())
+	assert.Len(pages[1], 11)
+}
@@ -0,0 +1,237 @@
+package slacknotify
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	"dsab.slacker/report"
+)
+
+var (
+	// userIdPattern matches Slack user IDs, eg. `U0123ABCD`
+	userIdPattern = regexp.MustCompile(`^[UW][A-Z0-9]{8,}$`)
+	// userGroupIdPattern matches Slack user group IDs, eg. `S0123ABCD`
+	userGroupIdPattern = regexp.MustCompile(`^S[A-Z0-9]{8,}$`)
+	// mentionPattern matches user & user group mentions, eg. `<@U0123ABCD>` or
+	// `<!subteam^S0123ABCD|@team>`
+	mentionPattern = regexp.MustCompile(`^<(@[UW][A-Z0-9]{8,}|!subteam\^S[A-Z0-9]{8,})(\|[^>]*)?>$`)
+)
+
+// StaticMention returns the mention of `owner` when it's a user or user group ID, or already
+// a mention. Other owners, eg. emails, must be looked up in Slack
+func StaticMention(owner string) (string, bool) {
+	switch {
+	case mentionPattern.MatchString(owner):
+		return owner, true
+	case userIdPattern.MatchString(owner):
+		return "<@" + owner + ">", true
+	case userGroupIdPattern.MatchString(owner):
+		return "<!subteam^" + owner + ">", true
+	default:
+		return "", false
+	}
+}
+
+// StaticMentions returns the mentions of the `owners` that don't need to be looked up in
+// Slack, eg. when rendering or sending via. a webhook
+func StaticMentions(owners []string) map[string]string {
+	mentions := map[string]string{}
+	for _, owner := range owners {
+		if mention, ok := StaticMention(owner); ok {
+			mentions[owner] = mention
+		}
+	}
+	return mentions
+}
+
+// ReportOwners collects every owner of the failing items in `reportJson` assigned by `rules`
+func ReportOwners(reportJson report.ReportJson, rules []report.OwnerRule) []string {
+	var (
+		owners = []string{}
+		seen   = map[string]bool{}
+	)
+
+	for _, env := range reportJson.Environments {
+		_, envOwners := env.Owners(rules)
+		for _, owner := range envOwners {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+
+	return owners
+}
+
+// NeedsUserLookup returns whether any of `owners` must be looked up with
+// `users.lookupByEmail`, which requires the `users:read.email` scope. See
+// `mentionResolver.WithEmailDomain` for `emailDomain`
+func NeedsUserLookup(owners []string, emailDomain string) bool {
+	for _, owner := range owners {
+		if _, ok := StaticMention(owner); !ok && ownerEmail(owner, emailDomain) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerEmail returns the email address `owner` is looked up by, or empty if it can't be
+func ownerEmail(owner string, emailDomain string) string {
+	emailDomain = strings.TrimPrefix(emailDomain, "@")
+
+	switch {
+	case strings.Contains(owner, "@") && !strings.HasPrefix(owner, "@"):
+		return owner
+	case emailDomain != "" && !strings.ContainsAny(owner, " <>"):
+		return strings.TrimPrefix(owner, "@") + "@" + emailDomain
+	default:
+		return ""
+	}
+}
+
+//-----------------------------------------------------------------------------------------
+
+// mentionResolver resolves owners to the mentions that ping them in Slack
+type mentionResolver struct {
+	client      *slack.Client
+	retryConfig RetryConfig
+	emailDomain string
+}
+
+func NewMentionResolver(tokenSource TokenSource, options ...slack.Option) (*mentionResolver, error) {
+	client, err := newClient(tokenSource, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mentionResolver{
+		client:      client,
+		retryConfig: DefaultRetryConfig(),
+	}, nil
+}
+
+// WithRetry sets how failed Slack API calls are retried
+func (r *mentionResolver) WithRetry(retryConfig RetryConfig) *mentionResolver {
+	r.retryConfig = retryConfig
+	return r
+}
+
+// WithEmailDomain resolves owners given as handles, eg. `@alice` or `alice`, as the email
+// address at `domain`, eg. `alice@example.com`. An empty domain leaves handles unresolved
+func (r *mentionResolver) WithEmailDomain(domain string) *mentionResolver {
+	r.emailDomain = domain
+	return r
+}
+
+// Resolve returns the mentions of `owners`. Owners given as emails or handles are looked up
+// with `users.lookupByEmail`. Owners that can't be resolved only go unmentioned, so are
+// logged rather than returned
+func (r *mentionResolver) Resolve(ctx context.Context, owners []string) map[string]string {
+	mentions := StaticMentions(owners)
+
+	for _, owner := range owners {
+		if _, ok := mentions[owner]; ok {
+			continue
+		}
+
+		email := ownerEmail(owner, r.emailDomain)
+		if email == "" {
+			// Owners have always been shown in the thread, so free text such as a team name is
+			// still valid, just not mentioned
+			log.Debugf("Not mentioning owner '%s', as it isn't a Slack ID, mention or email", owner)
+			continue
+		}
+
+		var user *slack.User
+		err := r.retryConfig.retry(ctx, "users.lookupByEmail", func() (err error) {
+			user, err = r.client.GetUserByEmailContext(ctx, email)
+			return err
+		})
+		if err != nil {
+			log.Warnf("Not mentioning owner '%s', as they couldn't be looked up by '%s': %v", owner, email, err)
+			continue
+		}
+
+		mentions[owner] = "<@" + user.ID + ">"
+	}
+
+	return mentions
+}
+
+//-----------------------------------------------------------------------------------------
+
+// environmentMentions are the mentions of the owners of an environment's failing items
+type environmentMentions struct {
+	All         []string
+	byNamespace map[string][]string
+}
+
+// buildEnvironmentMentions mentions the owners of the failing items in `env`, assigned by the
+// rules in `reportConfig`. Owners without a mention are left out
+func buildEnvironmentMentions(reportConfig report.ReportConfig, env report.ReportEnvironment) *environmentMentions {
+	byNamespace, all := env.Owners(reportConfig.OwnerRules)
+
+	mentions := &environmentMentions{
+		All:         ownerMentions(reportConfig.Mentions, all),
+		byNamespace: map[string][]string{},
+	}
+	for ns, owners := range byNamespace {
+		mentions.byNamespace[ns] = ownerMentions(reportConfig.Mentions, owners)
+	}
+
+	return mentions
+}
+
+// ownerMentions maps `owners` to their `mentions`, dropping duplicates & owners without one
+func ownerMentions(mentions map[string]string, owners []string) []string {
+	var (
+		mentioned = []string{}
+		seen      = map[string]bool{}
+	)
+
+	for _, owner := range owners {
+		if mention, ok := mentions[owner]; ok && !seen[mention] {
+			seen[mention] = true
+			mentioned = append(mentioned, mention)
+		}
+	}
+
+	return mentioned
+}
+
+// Namespace returns the mentions of the owners of the failing items in namespace `ns`
+func (m *environmentMentions) Namespace(ns string) []string {
+	if m == nil {
+		return nil
+	}
+	return m.byNamespace[ns]
+}
+
+// pingText is the text of a message pinging each of `mentions`, or empty without any
+func pingText(mentions []string) string {
+	if len(mentions) == 0 {
+		return ""
+	}
+	return "cc " + strings.Join(mentions, " ")
+}
+
+// newMentions returns the `mentions` that aren't in `previous`
+func newMentions(mentions []string, previous []string) []string {
+	seen := map[string]bool{}
+	for _, mention := range previous {
+		seen[mention] = true
+	}
+
+	added := []string{}
+	for _, mention := range mentions {
+		if !seen[mention] {
+			added = append(added, mention)
+		}
+	}
+	return added
+}
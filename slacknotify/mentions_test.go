@@ -0,0 +1,82 @@
+package slacknotify
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/report"
+)
+
+func TestStaticMention(t *testing.T) {
+	assert := assert.New(t)
+
+	for owner, expected := range map[string]string{
+		"U0ALICE123":                  "<@U0ALICE123>",
+		"W0ALICE123":                  "<@W0ALICE123>",
+		"S0PODS1234":                  "<!subteam^S0PODS1234>",
+		"<@U0ALICE123>":               "<@U0ALICE123>",
+		"<!subteam^S0PODS1234|@pods>": "<!subteam^S0PODS1234|@pods>",
+	} {
+		mention, ok := StaticMention(owner)
+		assert.True(ok, owner)
+		assert.Equal(expected, mention, owner)
+	}
+
+	for _, owner := range []string{"alice@example.com", "@alice", "platform-team", "U0ALICE"} {
+		_, ok := StaticMention(owner)
+		assert.False(ok, owner)
+	}
+}
+
+func TestNeedsUserLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(NeedsUserLookup([]string{"U0ALICE123", "platform-team", "@alice"}, ""))
+	assert.True(NeedsUserLookup([]string{"U0ALICE123", "@alice"}, "example.com"))
+	assert.True(NeedsUserLookup([]string{"alice@example.com"}, ""))
+
+	assert.Equal("alice@example.com", ownerEmail("@alice", "@example.com"))
+	assert.Equal("", ownerEmail("platform team", "example.com"))
+}
+
+func TestEnvironmentReportMentionsOwners(t *testing.T) {
+	assert := assert.New(t)
+
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: []report.Failure{{Id: "a", Owner: "alice@example.com"}}}}},
+		{Name: "ns2", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("b")}}},
+	}}
+	reportConfig := report.ReportConfig{
+		OwnerRules: []report.OwnerRule{{Namespace: "ns1", Owners: []string{"S0PODS1234", "platform-team"}}},
+		Mentions:   map[string]string{"alice@example.com": "<@U0ALICE123>", "S0PODS1234": "<!subteam^S0PODS1234>"},
+	}
+
+	msgs, err := buildEnvironmentReportMessages(DefaultTemplates(), reportConfig, env, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	// Owners without a mention are left out
+	assert.Equal("cc <@U0ALICE123> <!subteam^S0PODS1234>", msgs[0].Text)
+	assert.Equal([]string{"<@U0ALICE123>", "<!subteam^S0PODS1234>"}, mentionsOfPayload(roundTrip(t, msgs[0].Metadata.EventPayload)))
+
+	owners := msgs[0].Attachments[1].Blocks.BlockSet[1].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject)
+	assert.Equal(":bust_in_silhouette: Owners: <@U0ALICE123> <!subteam^S0PODS1234>", owners.Text)
+
+	// Namespaces without owners have no mentions
+	assert.IsType(&slack.ContextBlock{}, msgs[0].Attachments[2].Blocks.BlockSet[1])
+	header := msgs[0].Attachments[2].Blocks.BlockSet[1].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject)
+	assert.Contains(header.Text, "Failed Pods")
+
+	msgs, err = buildEnvironmentReportMessages(DefaultTemplates(), report.ReportConfig{}, env, 0)
+	require.NoError(t, err)
+	assert.Empty(msgs[0].Text)
+	assert.NotContains(msgs[0].Metadata.EventPayload, payloadMentions)
+}
+
+func TestNewMentions(t *testing.T) {
+	assert.Equal(t, []string{"<@U0BOB12345>"}, newMentions([]string{"<@U0ALICE123>", "<@U0BOB12345>"}, []string{"<@U0ALICE123>"}))
+	assert.Empty(t, newMentions([]string{"<@U0ALICE123>"}, []string{"<@U0ALICE123>", "<@U0BOB12345>"}))
+}
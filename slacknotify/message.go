@@ -0,0 +1,149 @@
+package slacknotify
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/slack-go/slack"
+
+	"dsab.slacker/report"
+)
+
+// BlockKitBuilderUrl is the Block Kit Builder, which previews the message encoded in the URL
+// fragment
+const BlockKitBuilderUrl = "https://app.slack.com/block-kit-builder/#"
+
+// Message is the exact payload of a single message sent to Slack
+type Message struct {
+	// Text pings the owners of the failures in the message, as mentions in blocks &
+	// attachments don't notify anyone
+	Text        string              `json:"text,omitempty"`
+	Blocks      []slack.Block       `json:"blocks,omitempty"`
+	Attachments []slack.Attachment  `json:"attachments,omitempty"`
+	Metadata    slack.SlackMetadata `json:"metadata"`
+}
+
+// msgOptions returns the options sending the message's content & metadata
+func (m Message) msgOptions() []slack.MsgOption {
+	opts := []slack.MsgOption{
+		slack.MsgOptionMetadata(m.Metadata),
+	}
+
+	if m.Text != "" {
+		opts = append(opts, slack.MsgOptionText(m.Text, false))
+	}
+
+	if m.Attachments != nil {
+		opts = append(opts, slack.MsgOptionAttachments(m.Attachments...))
+	} else {
+		opts = append(opts, slack.MsgOptionBlocks(m.Blocks...))
+	}
+
+	return opts
+}
+
+// BuilderUrl returns a Block Kit Builder link previewing the message. The builder doesn't
+// understand metadata, so only the blocks & attachments are included
+func (m Message) BuilderUrl() (string, error) {
+	bytes, err := json.Marshal(m.content())
+	if err != nil {
+		return "", err
+	}
+
+	return BlockKitBuilderUrl + url.PathEscape(string(bytes)), nil
+}
+
+// content returns the message without its metadata
+func (m Message) content() map[string]interface{} {
+	content := map[string]interface{}{}
+	if m.Text != "" {
+		content["text"] = m.Text
+	}
+	if m.Blocks != nil {
+		content["blocks"] = m.Blocks
+	}
+	if m.Attachments != nil {
+		content["attachments"] = m.Attachments
+	}
+	return content
+}
+
+// continuationMetadata tags part `part` of the overflow of a message, for `environment`
+// (empty for the summary report)
+func continuationMetadata(environment string, part int) slack.SlackMetadata {
+	payload := map[string]interface{}{
+		"part": part,
+	}
+	if environment != "" {
+		payload["environment"] = environment
+	}
+
+	return slack.SlackMetadata{
+		EventType:    BRING_UP_HEALTHCHECK_CONTINUATION,
+		EventPayload: payload,
+	}
+}
+
+// buildSummaryReportMessages lays out the summary report into the messages sent. The first
+// is the summary report itself, and the rest are continuations in its thread
+func buildSummaryReportMessages(templates *Templates, reportConfig report.ReportConfig, report report.ReportJson) ([]Message, error) {
+	blocks, err := buildSummaryReportBlocks(templates, reportConfig, report)
+	if err != nil {
+		return nil, err
+	}
+	pages := layoutSummaryReport(blocks)
+
+	msgs := make([]Message, len(pages))
+	for i, page := range pages {
+		msgs[i] = Message{
+			Blocks:   page,
+			Metadata: continuationMetadata("", i),
+		}
+	}
+
+	msgs[0].Metadata = slack.SlackMetadata{
+		EventType:    BRING_UP_HEALTHCHECK,
+		EventPayload: summaryReportPayload(reportConfig, report),
+	}
+
+	return msgs, nil
+}
+
+// buildEnvironmentReportMessages lays out an environment report into the messages sent. The
+// first is the environment reply, pinging the owners of the environment's failing items, and
+// the rest are continuations following it in the thread
+func buildEnvironmentReportMessages(templates *Templates, reportConfig report.ReportConfig, env report.ReportEnvironment, maxFailures int) ([]Message, error) {
+	diff := report.DiffEnvironment(reportConfig.PreviousReport, env)
+	mentions := buildEnvironmentMentions(reportConfig, env)
+	attachments, err := buildEnvironmentReport(templates, env, diff, maxFailures, mentions)
+	if err != nil {
+		return nil, err
+	}
+	pages := layoutEnvironmentReport(attachments)
+
+	msgs := make([]Message, len(pages))
+	for i, page := range pages {
+		msgs[i] = Message{
+			Attachments: page,
+			Metadata:    continuationMetadata(env.Name, i),
+		}
+	}
+
+	msgs[0].Text = pingText(mentions.All)
+	msgs[0].Metadata = slack.SlackMetadata{
+		EventType:    BRING_UP_HEALTHCHECK_ENVIRONMENT,
+		EventPayload: environmentReportPayload(env, mentions.All),
+	}
+
+	return msgs, nil
+}
+
+// shownFailures returns the maximum number of failures shown for `env` in the thread, or
+// zero if every failure is shown. Environments with more than `uploadThreshold` failures
+// only show the first `uploadTopFailures`, with the complete report uploaded as files
+func shownFailures(env report.ReportEnvironment, uploadThreshold int, uploadTopFailures int) int {
+	if uploadThreshold > 0 && env.Failures() > uploadThreshold {
+		return uploadTopFailures
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package slacknotify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"dsab.slacker/report"
+)
+
+// PayloadVersion is the version of the report encoding embedded in message metadata, bumped
+// whenever the encoding changes incompatibly
+const PayloadVersion = 1
+
+// maxEmbeddedReportSize limits the size of an encoded report embedded in message metadata,
+// as metadata counts towards the size of the message
+const maxEmbeddedReportSize = 16 * 1024
+
+// Metadata payload keys
+const (
+	payloadVersion   = "version"
+	payloadKind      = "kind"
+	payloadReport    = "report"
+	payloadHash      = "hash"
+	payloadErrors    = "errors"
+	payloadHealth    = "health"
+	payloadTruncated = "truncated"
+	payloadMentions  = "mentions"
+)
+
+// encodePayload encodes `v` as gzipped JSON, in base64 so it can be embedded in metadata
+func encodePayload(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodePayload decodes a payload encoded by `encodePayload` into `v`
+func decodePayload(encoded string, v any) error {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err = io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// reportHash identifies the content of a report, so that a report reconstructed from
+// metadata can be checked against the report that was sent
+func reportHash(reportJson report.ReportJson) string {
+	data, err := json.Marshal(reportJson)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// summaryReportPayload builds the summary report metadata. Besides the report date, it embeds
+// the report without the namespaces of completed environments, which are embedded in their
+// environment replies instead, along with a hash of the whole report & the errors & health of
+// each environment
+func summaryReportPayload(reportConfig report.ReportConfig, reportJson report.ReportJson) map[string]interface{} {
+	skeleton := reportJson
+	skeleton.Environments = make([]report.ReportEnvironment, len(reportJson.Environments))
+
+	errors := map[string]interface{}{}
+	health := map[string]interface{}{}
+	for i, env := range reportJson.Environments {
+		errors[env.Name] = env.Errors()
+		health[env.Name] = string(env.Health())
+
+		skeleton.Environments[i] = env
+		if env.Status == report.Completed {
+			skeleton.Environments[i].Namespaces = nil
+		}
+	}
+
+	payload := map[string]interface{}{
+		"date":         reportConfig.ReportDate,
+		payloadVersion: PayloadVersion,
+		payloadHash:    reportHash(reportJson),
+		payloadErrors:  errors,
+		payloadHealth:  health,
+	}
+	if reportConfig.Kind != "" {
+		payload[payloadKind] = reportConfig.Kind
+	}
+	embedPayload(payload, skeleton, log.WithField("report", reportConfig.ReportDate))
+
+	return payload
+}
+
+// payloadKindOf reads the kind of report from the summary report metadata, which is empty
+// for the default kind
+func payloadKindOf(payload map[string]interface{}) string {
+	kind, _ := payload[payloadKind].(string)
+	return kind
+}
+
+// environmentReportPayload builds the environment reply metadata, embedding the environment
+// & recording the `mentions` of its owners, so that they aren't pinged again on updates
+func environmentReportPayload(env report.ReportEnvironment, mentions []string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"environment":  env.Name,
+		payloadVersion: PayloadVersion,
+	}
+	if len(mentions) > 0 {
+		payload[payloadMentions] = mentions
+	}
+	embedPayload(payload, env, log.WithField("env", env.Name))
+
+	return payload
+}
+
+// mentionsOfPayload reads the mentions recorded in environment reply metadata
+func mentionsOfPayload(payload map[string]interface{}) []string {
+	switch values := payload[payloadMentions].(type) {
+	case []string:
+		return values
+	case []interface{}:
+		// As decoded from JSON
+		mentions := []string{}
+		for _, value := range values {
+			if mention, ok := value.(string); ok {
+				mentions = append(mentions, mention)
+			}
+		}
+		return mentions
+	default:
+		return nil
+	}
+}
+
+// embedPayload embeds the encoded `v` in `payload`, unless it's too large, in which case the
+// payload is marked as truncated. Failing to embed the report only affects reading it back
+// later, so is logged rather than returned
+func embedPayload(payload map[string]interface{}, v any, logger *log.Entry) {
+	encoded, err := encodePayload(v)
+	if err != nil {
+		logger.Warnf("failed to encode report metadata: %v", err)
+		payload[payloadTruncated] = true
+		return
+	}
+
+	if len(encoded) > maxEmbeddedReportSize {
+		logger.Warnf("Not embedding report in metadata, as it's too large (%d bytes)", len(encoded))
+		payload[payloadTruncated] = true
+		return
+	}
+
+	payload[payloadReport] = encoded
+}
+
+// decodeReportPayload decodes the report embedded in message metadata into `v`
+func decodeReportPayload(payload map[string]interface{}, v any) error {
+	// JSON numbers are decoded as float64
+	version, _ := payload[payloadVersion].(float64)
+	if version == 0 {
+		return fmt.Errorf("no report embedded in metadata - it was sent by an older version")
+	}
+	if int(version) != PayloadVersion {
+		return fmt.Errorf("unsupported report metadata version %d, expected %d", int(version), PayloadVersion)
+	}
+
+	if truncated, _ := payload[payloadTruncated].(bool); truncated {
+		return fmt.Errorf("report was too large to embed in metadata")
+	}
+
+	encoded, ok := payload[payloadReport].(string)
+	if !ok {
+		return fmt.Errorf("no report embedded in metadata")
+	}
+
+	if err := decodePayload(encoded, v); err != nil {
+		return fmt.Errorf("invalid report embedded in metadata: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package slacknotify
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+// roundTrip passes a metadata payload through JSON, as Slack does
+func roundTrip(t *testing.T, payload map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	decoded := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	return decoded
+}
+
+func TestSummaryReportPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	reportJson := report.ReportJson{
+		Metadata: report.ReportMetadata{JobName: "bring-up"},
+		Environments: []report.ReportEnvironment{
+			{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+				{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo", "bar")}}},
+			}},
+			{Name: "dev2", Status: report.Pending},
+		},
+	}
+
+	payload := roundTrip(t, summaryReportPayload(report.ReportConfig{ReportDate: "20-09-2023"}, reportJson))
+
+	assert.Equal("20-09-2023", payload["date"])
+	assert.Equal(map[string]interface{}{"dev1": float64(2), "dev2": float64(0)}, payload[payloadErrors])
+	assert.Equal(reportHash(reportJson), payload[payloadHash])
+	assert.NotContains(payload, payloadKind)
+
+	// The namespaces of completed environments are left to the environment replies
+	skeleton := report.ReportJson{}
+	assert.NoError(decodeReportPayload(payload, &skeleton))
+	assert.Equal("bring-up", skeleton.Metadata.JobName)
+	assert.Nil(skeleton.Environments[0].Namespaces)
+	assert.Equal(report.Pending, skeleton.Environments[1].Status)
+
+	env := report.ReportEnvironment{}
+	assert.NoError(decodeReportPayload(roundTrip(t, environmentReportPayload(reportJson.Environments[0], nil)), &env))
+	assert.Equal(reportJson.Environments[0], env)
+}
+
+func TestSummaryReportPayloadKind(t *testing.T) {
+	payload := roundTrip(t, summaryReportPayload(report.ReportConfig{ReportDate: "20-09-2023", Kind: "smoke"}, report.ReportJson{}))
+
+	assert.Equal(t, "smoke", payloadKindOf(payload))
+	assert.Equal(t, "", payloadKindOf(map[string]interface{}{"date": "20-09-2023"}))
+}
+
+func TestEnvironmentReportPayloadTooLarge(t *testing.T) {
+	assert := assert.New(t)
+
+	// Random-ish failures, so that they don't compress away
+	failures := []report.Failure{}
+	for i := 0; i < 5000; i++ {
+		failures = append(failures, report.Failure{Id: fmt.Sprintf("pod-%x", sha256.Sum256([]byte(fmt.Sprint(i))))})
+	}
+
+	payload := roundTrip(t, environmentReportPayload(report.ReportEnvironment{
+		Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+			{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: failures}}},
+		},
+	}, nil))
+
+	assert.Equal(true, payload[payloadTruncated])
+	assert.NotContains(payload, payloadReport)
+	assert.ErrorContains(decodeReportPayload(payload, &report.ReportEnvironment{}), "too large")
+}
+
+func TestDecodeReportPayloadVersions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.ErrorContains(decodeReportPayload(map[string]interface{}{"date": "20-09-2023"}, &report.ReportJson{}), "older version")
+	assert.ErrorContains(decodeReportPayload(map[string]interface{}{payloadVersion: float64(99)}, &report.ReportJson{}), "unsupported report metadata version 99")
+}
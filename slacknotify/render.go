@@ -0,0 +1,81 @@
+package slacknotify
+
+import (
+	"fmt"
+
+	"dsab.slacker/report"
+)
+
+// RenderedMessage is a message exactly as the live notifier would send it
+type RenderedMessage struct {
+	// Name identifies the message, eg. `summary`, `environment-dev1` or
+	// `environment-dev1-continuation-1`
+	Name       string  `json:"name"`
+	Message    Message `json:"message"`
+	BuilderUrl string  `json:"builder_url,omitempty"`
+}
+
+// renderer renders the messages sent for a report, without sending them
+type renderer struct {
+	reportConfig report.ReportConfig
+	templates    *Templates
+
+	uploadThreshold   int
+	uploadTopFailures int
+}
+
+func NewRenderer(reportConfig report.ReportConfig) *renderer {
+	return &renderer{
+		reportConfig: reportConfig,
+		templates:    DefaultTemplates(),
+	}
+}
+
+// WithTemplates sets the templates the messages are rendered with
+func (r *renderer) WithTemplates(templates *Templates) *renderer {
+	r.templates = templates
+	return r
+}
+
+// WithFileUpload matches the live notifier's `WithFileUpload`, so that the same failures are
+// shown
+func (r *renderer) WithFileUpload(threshold int, topFailures int) *renderer {
+	r.uploadThreshold = threshold
+	r.uploadTopFailures = topFailures
+	return r
+}
+
+// Render renders every message sent for a new report: the summary report, then a reply for
+// each environment, each followed by any continuations
+func (r *renderer) Render(reportJson report.ReportJson) ([]RenderedMessage, error) {
+	msgs, err := buildSummaryReportMessages(r.templates, r.reportConfig, reportJson)
+	if err != nil {
+		return nil, err
+	}
+	rendered := renderMessages("summary", msgs)
+
+	for _, env := range reportJson.Environments {
+		msgs, err := buildEnvironmentReportMessages(r.templates, r.reportConfig, env, shownFailures(env, r.uploadThreshold, r.uploadTopFailures))
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, renderMessages("environment-"+env.Name, msgs)...)
+	}
+
+	return rendered, nil
+}
+
+// renderMessages names a message & its continuations
+func renderMessages(name string, msgs []Message) []RenderedMessage {
+	rendered := make([]RenderedMessage, len(msgs))
+	for i, msg := range msgs {
+		rendered[i] = RenderedMessage{
+			Name:    name,
+			Message: msg,
+		}
+		if i > 0 {
+			rendered[i].Name = fmt.Sprintf("%s-continuation-%d", name, i)
+		}
+	}
+	return rendered
+}
@@ -0,0 +1,65 @@
+package slacknotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+func TestRenderNamesEveryMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	namespaces := make([]report.Namespace, 30)
+	for i := range namespaces {
+		namespaces[i] = report.Namespace{
+			Name:     fmt.Sprintf("ns-%d", i),
+			Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo")}},
+		}
+	}
+
+	rendered, err := NewRenderer(report.ReportConfig{ReportDate: "20-09-2023", BaseUrl: "https://reports"}).
+		Render(report.ReportJson{
+			Environments: []report.ReportEnvironment{
+				{Name: "dev1", Status: report.Completed, Namespaces: namespaces},
+				{Name: "dev2", Status: report.Pending},
+			},
+		})
+	assert.NoError(err)
+
+	names := []string{}
+	for _, msg := range rendered {
+		names = append(names, msg.Name)
+	}
+	assert.Equal([]string{"summary", "environment-dev1", "environment-dev1-continuation-1", "environment-dev1-continuation-2", "environment-dev2"}, names)
+
+	assert.Equal(BRING_UP_HEALTHCHECK, rendered[0].Message.Metadata.EventType)
+	assert.Equal("20-09-2023", rendered[0].Message.Metadata.EventPayload["date"])
+	assert.Equal(BRING_UP_HEALTHCHECK_ENVIRONMENT, rendered[1].Message.Metadata.EventType)
+	assert.Equal(BRING_UP_HEALTHCHECK_CONTINUATION, rendered[2].Message.Metadata.EventType)
+	assert.Equal(map[string]interface{}{"environment": "dev1", "part": 1}, rendered[2].Message.Metadata.EventPayload)
+}
+
+func TestMessageBuilderUrl(t *testing.T) {
+	assert := assert.New(t)
+
+	msgs, err := buildEnvironmentReportMessages(DefaultTemplates(), report.ReportConfig{}, report.ReportEnvironment{Name: "dev1", Status: report.Completed}, 0)
+	assert.NoError(err)
+
+	builderUrl, err := msgs[0].BuilderUrl()
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(builderUrl, BlockKitBuilderUrl))
+
+	fragment, err := url.PathUnescape(strings.TrimPrefix(builderUrl, BlockKitBuilderUrl))
+	assert.NoError(err)
+
+	content := map[string]interface{}{}
+	assert.NoError(json.Unmarshal([]byte(fragment), &content))
+	assert.Contains(content, "attachments")
+	assert.NotContains(content, "metadata")
+}
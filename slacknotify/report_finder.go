@@ -3,13 +3,31 @@ package slacknotify
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
+
+	"dsab.slacker/report"
 )
 
 type ReportFinder interface {
-	FindReport(date string) (*ResponseTimestamp, error)
-	FindEnvironmentReport(environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error)
+	FindReport(ctx context.Context, date string) (*ResponseTimestamp, error)
+	FindPreviousReport(ctx context.Context, date string, lookbackDays int) (*PreviousReport, error)
+	FindEnvironmentReport(ctx context.Context, environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error)
+	FindPermalink(ctx context.Context, responseTs ResponseTimestamp) (string, error)
+	FetchReport(ctx context.Context, date string) (*report.ReportJson, error)
+	FindPastReports(ctx context.Context, date string, lookbackDays int) ([]report.PastReport, error)
+}
+
+// PreviousReport is a summary report posted on an earlier date, with a permalink
+// that can be used to link to it
+type PreviousReport struct {
+	Date      string
+	Ts        ResponseTimestamp
+	Permalink string
 }
 
 // Interface assertions
@@ -20,52 +38,382 @@ var (
 
 //-----------------------------------------------------------------------------------------
 
+// historyPageSize is the number of messages requested per page of history or replies
+const historyPageSize = 200
+
+// DefaultMaxPages is the default limit on the pages of history or replies fetched in a
+// single lookup
+const DefaultMaxPages = 10
+
 type slackReportFinder struct {
-	channel string
-	client  *slack.Client
+	channel     string
+	kind        string
+	client      *slack.Client
+	retryConfig RetryConfig
+	maxPages    int
+
+	// threads caches the replies of each thread fetched, see `thread`
+	threads map[string][]slack.Message
 }
 
-func NewSlackReportFinder(token string, channel string) *slackReportFinder {
+// NewSlackReportFinder creates a finder looking up reports in `channel`, using the token from
+// `tokenSource`
+func NewSlackReportFinder(tokenSource TokenSource, channel string, options ...slack.Option) (*slackReportFinder, error) {
+	client, err := newClient(tokenSource, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSlackReportFinder(client, channel), nil
+}
+
+func newSlackReportFinder(client *slack.Client, channel string) *slackReportFinder {
 	return &slackReportFinder{
-		channel: channel,
-		client:  slack.New(token),
+		channel:     channel,
+		client:      client,
+		retryConfig: DefaultRetryConfig(),
+		maxPages:    DefaultMaxPages,
+		threads:     map[string][]slack.Message{},
 	}
 }
 
-func (s *slackReportFinder) FindReport(date string) (*ResponseTimestamp, error) {
-	msgs, err := s.client.GetConversationHistoryContext(context.Background(),
-		&slack.GetConversationHistoryParameters{
+// WithRetry sets how failed Slack API calls are retried
+func (s *slackReportFinder) WithRetry(retryConfig RetryConfig) *slackReportFinder {
+	s.retryConfig = retryConfig
+	return s
+}
+
+// WithKind only finds reports of `kind`, see `report.ReportConfig.Kind`
+func (s *slackReportFinder) WithKind(kind string) *slackReportFinder {
+	s.kind = kind
+	return s
+}
+
+// WithMaxPages limits the number of pages of history or replies fetched in a single lookup
+func (s *slackReportFinder) WithMaxPages(maxPages int) *slackReportFinder {
+	s.maxPages = maxPages
+	return s
+}
+
+// history fetches the messages in the channel posted since `oldest`, including their
+// metadata, following the cursor for up to `maxPages` pages
+func (s *slackReportFinder) history(ctx context.Context, oldest time.Time) ([]slack.Message, error) {
+	var (
+		msgs   []slack.Message
+		params = &slack.GetConversationHistoryParameters{
 			ChannelID:          s.channel,
 			IncludeAllMetadata: true,
-			Limit:              1000,
+			Limit:              historyPageSize,
+			Oldest:             fmt.Sprintf("%d.000000", oldest.Unix()),
+		}
+	)
+
+	for page := 1; ; page++ {
+		var resp *slack.GetConversationHistoryResponse
+
+		err := s.retryConfig.retry(ctx, "conversations.history", func() (err error) {
+			resp, err = s.client.GetConversationHistoryContext(ctx, params)
+			return err
 		})
-	if err != nil {
-		return nil, fmt.Errorf("error getting conversations: %s", err)
-	}
+		if err != nil {
+			return nil, fmt.Errorf("error getting conversations: %s", err)
+		}
 
-	for _, msg := range msgs.Messages {
-		if msg.Metadata.EventType == BRING_UP_HEALTHCHECK &&
-			msg.Metadata.EventPayload != nil &&
-			msg.Metadata.EventPayload["date"] == date {
-			responseTs := NewResponseTimestamp(msg.Timestamp)
+		msgs = append(msgs, resp.Messages...)
 
-			return &responseTs, nil
+		if !resp.HasMore || resp.ResponseMetaData.NextCursor == "" {
+			return msgs, nil
+		}
+		if page >= s.maxPages {
+			log.Warnf("Stopped searching channel history after %d pages - older messages were not searched", page)
+			return msgs, nil
 		}
-	}
 
-	return nil, nil
+		params.Cursor = resp.ResponseMetaData.NextCursor
+	}
 }
 
-func (s *slackReportFinder) FindEnvironmentReport(environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error) {
-	msgs, _, _, err := s.client.GetConversationRepliesContext(context.Background(),
-		&slack.GetConversationRepliesParameters{
+// replies fetches the messages in the thread of `responseTs`, including their metadata,
+// following the cursor for up to `maxPages` pages
+func (s *slackReportFinder) replies(ctx context.Context, responseTs ResponseTimestamp) ([]slack.Message, error) {
+	var (
+		msgs   []slack.Message
+		params = &slack.GetConversationRepliesParameters{
 			ChannelID:          s.channel,
 			Timestamp:          responseTs.Ts,
 			IncludeAllMetadata: true,
-			Limit:              1000,
+			Limit:              historyPageSize,
+		}
+	)
+
+	for page := 1; ; page++ {
+		var (
+			pageMsgs   []slack.Message
+			hasMore    bool
+			nextCursor string
+		)
+
+		err := s.retryConfig.retry(ctx, "conversations.replies", func() (err error) {
+			pageMsgs, hasMore, nextCursor, err = s.client.GetConversationRepliesContext(ctx, params)
+			return err
 		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting conversations: %s", err)
+		}
+
+		msgs = append(msgs, pageMsgs...)
+
+		if !hasMore || nextCursor == "" {
+			return msgs, nil
+		}
+		if page >= s.maxPages {
+			log.Warnf("Stopped searching thread replies after %d pages - later replies were not searched", page)
+			return msgs, nil
+		}
+
+		params.Cursor = nextCursor
+	}
+}
+
+// thread fetches the messages in the thread of `responseTs` the first time it's looked up,
+// returning the same messages after that, so that looking up each environment's replies
+// doesn't fetch the whole thread again. Replies posted since aren't seen, which is fine as
+// only replies sent by earlier runs are looked up
+func (s *slackReportFinder) thread(ctx context.Context, responseTs ResponseTimestamp) ([]slack.Message, error) {
+	if msgs, ok := s.threads[responseTs.Ts]; ok {
+		return msgs, nil
+	}
+
+	msgs, err := s.replies(ctx, responseTs)
+	if err != nil {
+		return nil, err
+	}
+	s.threads[responseTs.Ts] = msgs
+
+	return msgs, nil
+}
+
+// findSummaryReports collects the summary report messages of the finder's kind posted since
+// `oldest`, keyed by their report date. Only the most recent message is kept for each date
+func (s *slackReportFinder) findSummaryReports(ctx context.Context, oldest time.Time) (map[string]slack.Message, error) {
+	msgs, err := s.history(ctx, oldest)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[string]slack.Message{}
+	for _, msg := range msgs {
+		if msg.Metadata.EventType != BRING_UP_HEALTHCHECK || msg.Metadata.EventPayload == nil {
+			continue
+		}
+
+		if payloadKindOf(msg.Metadata.EventPayload) != s.kind {
+			continue
+		}
+
+		date, ok := msg.Metadata.EventPayload["date"].(string)
+		if !ok {
+			continue
+		}
+
+		// History is returned newest first
+		if _, exists := reports[date]; !exists {
+			reports[date] = msg
+		}
+	}
+
+	return reports, nil
+}
+
+// findSummaryReport finds the summary report message for `date`, or nil if there isn't one
+func (s *slackReportFinder) findSummaryReport(ctx context.Context, date string) (*slack.Message, error) {
+	reportDate, err := time.Parse(report.DateFormat, date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report date '%s': %v", date, err)
+	}
+
+	// A report can't be posted before its report date, allowing a day for timezones
+	reports, err := s.findSummaryReports(ctx, reportDate.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	if msg, ok := reports[date]; ok {
+		return &msg, nil
+	}
+
+	return nil, nil
+}
+
+func (s *slackReportFinder) FindReport(ctx context.Context, date string) (*ResponseTimestamp, error) {
+	msg, err := s.findSummaryReport(ctx, date)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	responseTs := NewResponseTimestamp(msg.Timestamp)
+
+	return &responseTs, nil
+}
+
+// FetchReport reconstructs the report sent for `date` from the metadata embedded in the
+// summary report & environment replies, or returns nil if there is no report for `date`.
+// An error is returned if the reconstructed report doesn't match the report that was sent,
+// eg. as an environment was too large to embed, rather than returning a partial report
+func (s *slackReportFinder) FetchReport(ctx context.Context, date string) (*report.ReportJson, error) {
+	msg, err := s.findSummaryReport(ctx, date)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	reportJson := report.ReportJson{}
+	if err := decodeReportPayload(msg.Metadata.EventPayload, &reportJson); err != nil {
+		return nil, fmt.Errorf("could not read report from %s: %v", date, err)
+	}
+
+	replies, err := s.replies(ctx, NewResponseTimestamp(msg.Timestamp))
+	if err != nil {
+		return nil, err
+	}
+
+	environments := map[string]report.ReportEnvironment{}
+	for _, reply := range replies {
+		if reply.Metadata.EventType != BRING_UP_HEALTHCHECK_ENVIRONMENT || reply.Metadata.EventPayload == nil {
+			continue
+		}
+
+		env := report.ReportEnvironment{}
+		if err := decodeReportPayload(reply.Metadata.EventPayload, &env); err != nil {
+			log.WithField("env", reply.Metadata.EventPayload["environment"]).Warnf("could not read environment report: %v", err)
+			continue
+		}
+		environments[env.Name] = env
+	}
+
+	missing := []string{}
+	for i, env := range reportJson.Environments {
+		if env.Status != report.Completed {
+			continue
+		}
+
+		if sent, ok := environments[env.Name]; ok {
+			reportJson.Environments[i] = sent
+		} else {
+			missing = append(missing, env.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("report from %s is incomplete, as environments %s couldn't be read back", date, strings.Join(missing, ", "))
+	}
+	if hash, _ := msg.Metadata.EventPayload[payloadHash].(string); hash != reportHash(reportJson) {
+		return nil, fmt.Errorf("report from %s is incomplete, as it doesn't match the report that was sent", date)
+	}
+
+	return &reportJson, nil
+}
+
+// FindPreviousReport searches back up to `lookbackDays` days before `date` for the most
+// recent summary report, returning its permalink
+func (s *slackReportFinder) FindPreviousReport(ctx context.Context, date string, lookbackDays int) (*PreviousReport, error) {
+	reportDate, err := time.Parse(report.DateFormat, date)
 	if err != nil {
-		return nil, fmt.Errorf("error getting conversations: %s", err)
+		return nil, fmt.Errorf("invalid report date '%s': %v", date, err)
+	}
+
+	reports, err := s.findSummaryReports(ctx, reportDate.AddDate(0, 0, -lookbackDays-1))
+	if err != nil {
+		return nil, err
+	}
+
+	for day := 1; day <= lookbackDays; day++ {
+		previousDate := reportDate.AddDate(0, 0, -day).Format(report.DateFormat)
+
+		msg, ok := reports[previousDate]
+		if !ok {
+			continue
+		}
+
+		permalink, err := s.FindPermalink(ctx, NewResponseTimestamp(msg.Timestamp))
+		if err != nil {
+			return nil, err
+		}
+
+		return &PreviousReport{
+			Date:      previousDate,
+			Ts:        NewResponseTimestamp(msg.Timestamp),
+			Permalink: permalink,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// FindPastReports collects the errors & health of each completed environment of the summary reports
+// posted up to `lookbackDays` days before `date`, oldest first, from their metadata
+func (s *slackReportFinder) FindPastReports(ctx context.Context, date string, lookbackDays int) ([]report.PastReport, error) {
+	reportDate, err := time.Parse(report.DateFormat, date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report date '%s': %v", date, err)
+	}
+
+	reports, err := s.findSummaryReports(ctx, reportDate.AddDate(0, 0, -lookbackDays-1))
+	if err != nil {
+		return nil, err
+	}
+
+	past := []report.PastReport{}
+	for day := lookbackDays; day >= 1; day-- {
+		pastDate := reportDate.AddDate(0, 0, -day).Format(report.DateFormat)
+		if msg, ok := reports[pastDate]; ok {
+			past = append(past, pastReportFromPayload(pastDate, msg.Metadata.EventPayload))
+		}
+	}
+
+	return past, nil
+}
+
+// pastReportFromPayload reads the errors & health of each completed environment from the
+// summary report metadata. When the report was too large to embed, the environment statuses
+// aren't known, so every environment is assumed to have completed
+func pastReportFromPayload(date string, payload map[string]interface{}) report.PastReport {
+	pastReport := report.PastReport{Date: date, Errors: map[string]int{}, Health: map[string]report.Health{}}
+
+	completed := map[string]bool{}
+	skeleton := report.ReportJson{}
+	embedded := decodeReportPayload(payload, &skeleton) == nil
+	for _, env := range skeleton.Environments {
+		completed[env.Name] = env.Status == report.Completed
+	}
+
+	errors, _ := payload[payloadErrors].(map[string]interface{})
+	for env, value := range errors {
+		// JSON numbers are decoded as float64
+		count, ok := value.(float64)
+		if !ok || (embedded && !completed[env]) {
+			continue
+		}
+		pastReport.Errors[env] = int(count)
+	}
+
+	// Reports sent before health was recorded only have errors
+	health, _ := payload[payloadHealth].(map[string]interface{})
+	for env, value := range health {
+		if _, ok := pastReport.Errors[env]; !ok {
+			continue
+		}
+		if value, ok := value.(string); ok {
+			pastReport.Health[env] = report.Health(value)
+		}
+	}
+
+	return pastReport
+}
+
+func (s *slackReportFinder) FindEnvironmentReport(ctx context.Context, environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error) {
+	msgs, err := s.thread(ctx, responseTs)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, msg := range msgs {
@@ -81,6 +429,106 @@ func (s *slackReportFinder) FindEnvironmentReport(environment string, responseTs
 	return nil, nil
 }
 
+// findMentions finds the mentions recorded in the environment reply at `replyTs`, in the
+// thread of `responseTs`
+func (s *slackReportFinder) findMentions(ctx context.Context, responseTs ResponseTimestamp, replyTs ResponseTimestamp) ([]string, error) {
+	msgs, err := s.thread(ctx, responseTs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range msgs {
+		if msg.Timestamp == replyTs.Ts && msg.Metadata.EventType == BRING_UP_HEALTHCHECK_ENVIRONMENT {
+			return mentionsOfPayload(msg.Metadata.EventPayload), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// FindPermalink gets the permalink to the message at `responseTs`
+func (s *slackReportFinder) FindPermalink(ctx context.Context, responseTs ResponseTimestamp) (string, error) {
+	var permalink string
+
+	err := s.retryConfig.retry(ctx, "chat.getPermalink", func() (err error) {
+		permalink, err = s.client.GetPermalinkContext(ctx,
+			&slack.PermalinkParameters{
+				Channel: s.channel,
+				Ts:      responseTs.Ts,
+			})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting permalink: %s", err)
+	}
+
+	return permalink, nil
+}
+
+// findContinuations finds the continuation replies for `environment` in the thread of
+// `responseTs`, ordered by part number. An empty `environment` finds the continuations
+// of the summary report itself
+func (s *slackReportFinder) findContinuations(ctx context.Context, environment string, responseTs ResponseTimestamp) ([]ResponseTimestamp, error) {
+	msgs, err := s.thread(ctx, responseTs)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := map[int]ResponseTimestamp{}
+	for _, msg := range msgs {
+		if msg.Metadata.EventType != BRING_UP_HEALTHCHECK_CONTINUATION || msg.Metadata.EventPayload == nil {
+			continue
+		}
+
+		msgEnvironment, _ := msg.Metadata.EventPayload["environment"].(string)
+		if msgEnvironment != environment {
+			continue
+		}
+
+		// JSON numbers are decoded as float64
+		part, ok := msg.Metadata.EventPayload["part"].(float64)
+		if !ok {
+			continue
+		}
+		parts[int(part)] = NewResponseTimestamp(msg.Timestamp)
+	}
+
+	partNumbers := make([]int, 0, len(parts))
+	for part := range parts {
+		partNumbers = append(partNumbers, part)
+	}
+	sort.Ints(partNumbers)
+
+	continuations := make([]ResponseTimestamp, 0, len(parts))
+	for _, part := range partNumbers {
+		continuations = append(continuations, parts[part])
+	}
+
+	return continuations, nil
+}
+
+// findUploadedFiles finds the IDs of files named any of `filenames` that were uploaded into
+// the thread of `responseTs`
+func (s *slackReportFinder) findUploadedFiles(ctx context.Context, responseTs ResponseTimestamp, filenames ...string) ([]string, error) {
+	msgs, err := s.thread(ctx, responseTs)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIds := []string{}
+	for _, msg := range msgs {
+		for _, file := range msg.Files {
+			for _, filename := range filenames {
+				if file.Name == filename {
+					fileIds = append(fileIds, file.ID)
+				}
+			}
+		}
+	}
+
+	return fileIds, nil
+}
+
 //------------------------------------------------------------------------------
 
 type noOpReportFinder struct{}
@@ -89,10 +537,26 @@ func NewNoOpReportFinder() *noOpReportFinder {
 	return &noOpReportFinder{}
 }
 
-func (s *noOpReportFinder) FindReport(date string) (*ResponseTimestamp, error) {
+func (s *noOpReportFinder) FindReport(ctx context.Context, date string) (*ResponseTimestamp, error) {
+	return nil, nil
+}
+
+func (s *noOpReportFinder) FindPreviousReport(ctx context.Context, date string, lookbackDays int) (*PreviousReport, error) {
+	return nil, nil
+}
+
+func (s *noOpReportFinder) FindEnvironmentReport(ctx context.Context, environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error) {
+	return nil, nil
+}
+
+func (s *noOpReportFinder) FindPermalink(ctx context.Context, responseTs ResponseTimestamp) (string, error) {
+	return "", nil
+}
+
+func (s *noOpReportFinder) FetchReport(ctx context.Context, date string) (*report.ReportJson, error) {
 	return nil, nil
 }
 
-func (s *noOpReportFinder) FindEnvironmentReport(environment string, responseTs ResponseTimestamp) (*ResponseTimestamp, error) {
+func (s *noOpReportFinder) FindPastReports(ctx context.Context, date string, lookbackDays int) ([]report.PastReport, error) {
 	return nil, nil
 }
@@ -0,0 +1,135 @@
+package slacknotify
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// RetryConfig controls how failed Slack API calls are retried. Calls are retried when they
+// are rate limited, or fail with a server or network error. Calls that post messages or files
+// are only retried when rate limited, see `retryPost`
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of times a call is made, including the first
+	MaxAttempts int
+	// MaxElapsed is the maximum total time spent on a call, including waiting between retries
+	MaxElapsed time.Duration
+	// BaseDelay is the delay before the first retry, doubling with each subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries, unless Slack asks us to wait longer
+	MaxDelay time.Duration
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 5,
+		MaxElapsed:  2 * time.Minute,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// retryDelay determines how long to wait before retrying a failed call, and whether the
+// failure can be retried at all
+func (r RetryConfig) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, true
+	}
+
+	var retryable interface{ Retryable() bool }
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return 0, false
+	case errors.As(err, &retryable):
+		if !retryable.Retryable() {
+			return 0, false
+		}
+	case errors.As(err, &netErr):
+	default:
+		return 0, false
+	}
+
+	// Exponential backoff, with jitter of up to half the delay
+	delay := r.BaseDelay << (attempt - 1)
+	if delay > r.MaxDelay || delay <= 0 {
+		delay = r.MaxDelay
+	}
+	if delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+	}
+
+	return delay, true
+}
+
+// rateLimitedDelay is `retryDelay` for calls that are only retried when rate limited
+func (r RetryConfig) rateLimitedDelay(err error, attempt int) (time.Duration, bool) {
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, true
+	}
+	return 0, false
+}
+
+// retry calls `call` until it succeeds, fails with an error that can't be retried, the
+// attempt or time budget runs out, or `ctx` is done. `operation` names the call in the log
+func (r RetryConfig) retry(ctx context.Context, operation string, call func() error) error {
+	return r.retryWith(ctx, operation, r.retryDelay, call)
+}
+
+// retryPost is `retry` for calls that post a message or file. A post that failed with a
+// server or network error may still have been made, so retrying it could post it twice. Only
+// rate limited posts, which Slack rejected, are retried
+func (r RetryConfig) retryPost(ctx context.Context, operation string, call func() error) error {
+	return r.retryWith(ctx, operation, r.rateLimitedDelay, call)
+}
+
+// retryWith calls `call` until it succeeds, or `retryDelay` says its error can't be retried,
+// subject to the attempt & time budgets
+func (r RetryConfig) retryWith(ctx context.Context, operation string, retryDelay func(err error, attempt int) (time.Duration, bool), call func() error) error {
+	var (
+		start  = time.Now()
+		logger = log.WithField("operation", operation)
+	)
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			if attempt > 1 {
+				logger.Infof("Succeeded after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		delay, ok := retryDelay(err, attempt)
+		if !ok {
+			return err
+		}
+
+		if attempt >= r.MaxAttempts {
+			logger.Warnf("Giving up after %d attempts: %v", attempt, err)
+			return err
+		}
+
+		if r.MaxElapsed > 0 && time.Since(start)+delay > r.MaxElapsed {
+			logger.Warnf("Giving up after %d attempts, as retrying would exceed %s: %v", attempt, r.MaxElapsed, err)
+			return err
+		}
+
+		logger.Warnf("Attempt %d/%d failed, retrying in %s: %v", attempt, r.MaxAttempts, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
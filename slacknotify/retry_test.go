@@ -0,0 +1,85 @@
+package slacknotify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	retryConfig := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	delay, ok := retryConfig.retryDelay(&slack.RateLimitedError{RetryAfter: 7 * time.Second}, 1)
+	assert.True(ok)
+	assert.Equal(7*time.Second, delay)
+
+	_, ok = retryConfig.retryDelay(slack.StatusCodeError{Code: 502}, 1)
+	assert.True(ok)
+
+	_, ok = retryConfig.retryDelay(slack.SlackErrorResponse{Err: "channel_not_found"}, 1)
+	assert.False(ok)
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	assert := assert.New(t)
+
+	retryConfig := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	attempts := 0
+	err := retryConfig.retry(context.Background(), "test", func() error {
+		attempts++
+		return slack.StatusCodeError{Code: 503}
+	})
+	assert.Error(err)
+	assert.Equal(3, attempts)
+
+	attempts = 0
+	err = retryConfig.retry(context.Background(), "test", func() error {
+		attempts++
+		if attempts == 1 {
+			return slack.StatusCodeError{Code: 500}
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(2, attempts)
+
+	attempts = 0
+	err = retryConfig.retry(context.Background(), "test", func() error {
+		attempts++
+		return errors.New("invalid_blocks")
+	})
+	assert.Error(err)
+	assert.Equal(1, attempts)
+}
+
+func TestRetryPostOnlyRetriesRateLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	retryConfig := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	attempts := 0
+	err := retryConfig.retryPost(context.Background(), "test", func() error {
+		attempts++
+		return slack.StatusCodeError{Code: 503}
+	})
+	assert.Error(err)
+	assert.Equal(1, attempts)
+
+	attempts = 0
+	err = retryConfig.retryPost(context.Background(), "test", func() error {
+		attempts++
+		if attempts == 1 {
+			return &slack.RateLimitedError{RetryAfter: time.Millisecond}
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(2, attempts)
+}
@@ -3,6 +3,7 @@ package slacknotify
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
@@ -13,6 +14,10 @@ import (
 const (
 	BRING_UP_HEALTHCHECK             = "bring_up_healthcheck"
 	BRING_UP_HEALTHCHECK_ENVIRONMENT = "bring_up_healthcheck_environment"
+
+	// BRING_UP_HEALTHCHECK_CONTINUATION tags thread replies holding the overflow of a
+	// message that was too large to send in one piece
+	BRING_UP_HEALTHCHECK_CONTINUATION = "bring_up_healthcheck_continuation"
 )
 
 //-----------------------------------------------------------------------------------------
@@ -34,14 +39,18 @@ func (rt *ResponseTimestamp) IsEmpty() bool {
 //-----------------------------------------------------------------------------------------
 
 type SlackNotifier interface {
-	SendSummaryReport(report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error)
-	SendEnvironmentReport(parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) error
+	// Render renders every message sent for a new report, without sending them, so that
+	// template errors are found before anything is sent
+	Render(report report.ReportJson) ([]RenderedMessage, error)
+	SendSummaryReport(ctx context.Context, report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error)
+	SendEnvironmentReport(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) (envReportTs ResponseTimestamp, err error)
 }
 
 // Interface assertions
 var (
 	_ SlackNotifier = (*slackNotifierConfig)(nil)
 	_ SlackNotifier = (*debugNotifier)(nil)
+	_ SlackNotifier = (*webhookNotifier)(nil)
 )
 
 //-----------------------------------------------------------------------------------------
@@ -50,8 +59,14 @@ var (
 type slackNotifierConfig struct {
 	channel      string
 	reportConfig report.ReportConfig
+	templates    *Templates
 	client       *slack.Client
+	finder       *slackReportFinder
 	username     string
+	retryConfig  RetryConfig
+
+	uploadThreshold   int
+	uploadTopFailures int
 }
 
 func (c *slackNotifierConfig) WithUsername(username string) *slackNotifierConfig {
@@ -59,97 +74,319 @@ func (c *slackNotifierConfig) WithUsername(username string) *slackNotifierConfig
 	return c
 }
 
-func NewNotifier(token string, channel string, reportConfig report.ReportConfig) *slackNotifierConfig {
+// WithTemplates sets the templates the messages are rendered with
+func (c *slackNotifierConfig) WithTemplates(templates *Templates) *slackNotifierConfig {
+	c.templates = templates
+	return c
+}
+
+// WithRetry sets how failed Slack API calls are retried
+func (c *slackNotifierConfig) WithRetry(retryConfig RetryConfig) *slackNotifierConfig {
+	c.retryConfig = retryConfig
+	c.finder.retryConfig = retryConfig
+	return c
+}
+
+// WithMaxPages limits the number of pages of thread replies fetched when looking up
+// existing continuations & uploads
+func (c *slackNotifierConfig) WithMaxPages(maxPages int) *slackNotifierConfig {
+	c.finder.maxPages = maxPages
+	return c
+}
+
+// WithFinder looks up existing replies with `finder`, so that a thread fetched to find
+// the environment replies isn't fetched again to find their continuations & uploads
+func (c *slackNotifierConfig) WithFinder(finder ReportFinder) *slackNotifierConfig {
+	if finder, ok := finder.(*slackReportFinder); ok {
+		c.finder = finder
+	}
+	return c
+}
+
+// WithFileUpload enables uploading the complete report for environments with more than
+// `threshold` failures, showing only the first `topFailures` failures in the thread
+func (c *slackNotifierConfig) WithFileUpload(threshold int, topFailures int) *slackNotifierConfig {
+	c.uploadThreshold = threshold
+	c.uploadTopFailures = topFailures
+	return c
+}
+
+// NewNotifier creates a notifier sending to `channel`, using the token from `tokenSource`
+func NewNotifier(tokenSource TokenSource, channel string, reportConfig report.ReportConfig, options ...slack.Option) (*slackNotifierConfig, error) {
+	client, err := newClient(tokenSource, options...)
+	if err != nil {
+		return nil, err
+	}
+
 	return &slackNotifierConfig{
 		channel:      channel,
-		client:       slack.New(token),
+		client:       client,
+		finder:       newSlackReportFinder(client, channel),
 		reportConfig: reportConfig,
-	}
+		templates:    DefaultTemplates(),
+		retryConfig:  DefaultRetryConfig(),
+	}, nil
 }
 
-func (c *slackNotifierConfig) SendSummaryReport(report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error) {
+func (c *slackNotifierConfig) Render(report report.ReportJson) ([]RenderedMessage, error) {
+	return NewRenderer(c.reportConfig).
+		WithTemplates(c.templates).
+		WithFileUpload(c.uploadThreshold, c.uploadTopFailures).
+		Render(report)
+}
+
+func (c *slackNotifierConfig) SendSummaryReport(ctx context.Context, report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error) {
 	var respTimestamp string
 
-	opts := []slack.MsgOption{
-		slack.MsgOptionMetadata(slack.SlackMetadata{
-			EventType: BRING_UP_HEALTHCHECK,
-			EventPayload: map[string]interface{}{
-				"date": c.reportConfig.ReportDate,
-			},
-		}),
+	msgs, err := buildSummaryReportMessages(c.templates, c.reportConfig, report)
+	if err != nil {
+		return NewResponseTimestamp(""), err
+	}
+
+	opts := append([]slack.MsgOption{
 		slack.MsgOptionDisableLinkUnfurl(),
 		slack.MsgOptionUsername(c.username),
-		slack.MsgOptionBlocks(buildSummaryReportBlocks(c.reportConfig, report)...),
-	}
+	}, msgs[0].msgOptions()...)
 
 	if updateMessageTs != nil {
 		log.Debug("Updating existing summary report")
-		_, respTimestamp, _, err = c.client.UpdateMessageContext(
-			context.TODO(),
-			c.channel,
-			updateMessageTs.Ts,
-			opts...,
-		)
+		err = c.retryConfig.retry(ctx, "chat.update", func() (err error) {
+			_, respTimestamp, _, err = c.client.UpdateMessageContext(
+				ctx,
+				c.channel,
+				updateMessageTs.Ts,
+				opts...,
+			)
+			return err
+		})
 	} else {
 		log.Debug("Creating new summary report")
-		_, respTimestamp, err = c.client.PostMessageContext(
-			context.TODO(),
-			c.channel,
-			opts...,
-		)
+		err = c.retryConfig.retryPost(ctx, "chat.postMessage", func() (err error) {
+			_, respTimestamp, err = c.client.PostMessageContext(
+				ctx,
+				c.channel,
+				opts...,
+			)
+			return err
+		})
 	}
-	return NewResponseTimestamp(respTimestamp), err
+	if err != nil {
+		return NewResponseTimestamp(respTimestamp), err
+	}
+
+	summaryReportTs = NewResponseTimestamp(respTimestamp)
+	err = c.sendContinuations(ctx, summaryReportTs, "", msgs[1:], updateMessageTs != nil)
+
+	return summaryReportTs, err
 }
 
-func (c *slackNotifierConfig) SendEnvironmentReport(parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) (err error) {
-	opts := []slack.MsgOption{
+func (c *slackNotifierConfig) SendEnvironmentReport(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) (envReportTs ResponseTimestamp, err error) {
+	var (
+		respTimestamp string
+		maxFailures   = shownFailures(env, c.uploadThreshold, c.uploadTopFailures)
+		upload        = maxFailures > 0
+	)
+
+	msgs, err := buildEnvironmentReportMessages(c.templates, c.reportConfig, env, maxFailures)
+	if err != nil {
+		return NewResponseTimestamp(""), err
+	}
+
+	// Edits don't notify anyone, so owners added since the reply was sent are pinged
+	// separately. Owners who were already pinged aren't pinged again
+	var pings []string
+	if mentions := mentionsOfPayload(msgs[0].Metadata.EventPayload); updateMessageTs != nil && len(mentions) > 0 {
+		previous, err := c.finder.findMentions(ctx, parentMessageTs, *updateMessageTs)
+		if err != nil {
+			return NewResponseTimestamp(""), err
+		}
+		pings = newMentions(mentions, previous)
+	}
+
+	opts := append([]slack.MsgOption{
 		slack.MsgOptionTS(parentMessageTs.Ts),
 		slack.MsgOptionDisableLinkUnfurl(),
-		slack.MsgOptionMetadata(slack.SlackMetadata{
-			EventType: BRING_UP_HEALTHCHECK_ENVIRONMENT,
-			EventPayload: map[string]interface{}{
-				"environment": env.Name,
-			},
-		}),
 		slack.MsgOptionUsername(c.username),
-		slack.MsgOptionAttachments(buildEnvironmentReport(env)...),
-	}
+	}, msgs[0].msgOptions()...)
 
 	if updateMessageTs != nil {
 		log.WithField("env", env.Name).Debug("Updating existing environment report")
-		_, _, _, err = c.client.UpdateMessageContext(
-			context.TODO(),
-			c.channel,
-			updateMessageTs.Ts,
-			opts...,
-		)
+		err = c.retryConfig.retry(ctx, "chat.update", func() (err error) {
+			_, respTimestamp, _, err = c.client.UpdateMessageContext(
+				ctx,
+				c.channel,
+				updateMessageTs.Ts,
+				opts...,
+			)
+			return err
+		})
 
 	} else {
 		log.WithField("env", env.Name).Debug("Creating new environment report")
-		_, _, err = c.client.PostMessageContext(
-			context.TODO(),
-			c.channel,
-			opts...,
+		err = c.retryConfig.retryPost(ctx, "chat.postMessage", func() (err error) {
+			_, respTimestamp, err = c.client.PostMessageContext(
+				ctx,
+				c.channel,
+				opts...,
+			)
+			return err
+		})
+	}
+	if err != nil {
+		return NewResponseTimestamp(respTimestamp), err
+	}
+	envReportTs = NewResponseTimestamp(respTimestamp)
+
+	if err := c.sendContinuations(ctx, parentMessageTs, env.Name, msgs[1:], updateMessageTs != nil); err != nil {
+		return envReportTs, err
+	}
+
+	if len(pings) > 0 {
+		if err := c.sendPing(ctx, parentMessageTs, env.Name, pings); err != nil {
+			return envReportTs, err
+		}
+	}
+
+	if updateMessageTs != nil {
+		if err := c.deleteEnvironmentReportUploads(ctx, parentMessageTs, env); err != nil {
+			return envReportTs, err
+		}
+	}
+
+	if upload {
+		return envReportTs, c.uploadEnvironmentReport(ctx, parentMessageTs, env)
+	}
+
+	return envReportTs, nil
+}
+
+// sendContinuations posts the overflow `msgs` of a message as replies in the thread of
+// `parentMessageTs`, tagged with their environment (empty for the summary report) & part
+// number. When `update` is set, existing continuations are updated in place and any that
+// are no longer needed are deleted
+func (c *slackNotifierConfig) sendContinuations(ctx context.Context, parentMessageTs ResponseTimestamp, environment string, msgs []Message, update bool) error {
+	var existing []ResponseTimestamp
+
+	logger := log.WithField("env", environment)
+
+	if update {
+		var err error
+		existing, err = c.finder.findContinuations(ctx, environment, parentMessageTs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, msg := range msgs {
+		part := i + 1
+
+		opts := append([]slack.MsgOption{
+			slack.MsgOptionTS(parentMessageTs.Ts),
+			slack.MsgOptionDisableLinkUnfurl(),
+			slack.MsgOptionUsername(c.username),
+		}, msg.msgOptions()...)
+
+		var err error
+		if i < len(existing) {
+			logger.Debugf("Updating existing continuation %d", part)
+			err = c.retryConfig.retry(ctx, "chat.update", func() (err error) {
+				_, _, _, err = c.client.UpdateMessageContext(ctx, c.channel, existing[i].Ts, opts...)
+				return err
+			})
+		} else {
+			logger.Debugf("Creating new continuation %d", part)
+			err = c.retryConfig.retryPost(ctx, "chat.postMessage", func() (err error) {
+				_, _, err = c.client.PostMessageContext(ctx, c.channel, opts...)
+				return err
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send continuation %d: %v", part, err)
+		}
+	}
+
+	for i := len(msgs); i < len(existing); i++ {
+		logger.Debugf("Deleting stale continuation %d", i+1)
+		err := c.retryConfig.retry(ctx, "chat.delete", func() (err error) {
+			_, _, err = c.client.DeleteMessageContext(ctx, c.channel, existing[i].Ts)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete continuation %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// sendPing posts a reply in the thread of `parentMessageTs` pinging `mentions`, the owners of
+// the failing items in `environment` who weren't mentioned when its reply was first sent.
+// The reply has no metadata, so it's never mistaken for part of the report
+func (c *slackNotifierConfig) sendPing(ctx context.Context, parentMessageTs ResponseTimestamp, environment string, mentions []string) error {
+	log.WithField("env", environment).Debugf("Pinging %d new owners", len(mentions))
+
+	err := c.retryConfig.retryPost(ctx, "chat.postMessage", func() (err error) {
+		_, _, err = c.client.PostMessageContext(ctx, c.channel,
+			slack.MsgOptionTS(parentMessageTs.Ts),
+			slack.MsgOptionDisableLinkUnfurl(),
+			slack.MsgOptionUsername(c.username),
+			slack.MsgOptionText(fmt.Sprintf("%s: %s", environment, pingText(mentions)), false),
 		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ping the new owners of %s: %v", environment, err)
 	}
 
-	return err
+	return nil
 }
 
 //-----------------------------------------------------------------------------------------
 // Debug
 
+// debugNotifier logs the messages that would be sent at debug level, without sending them
 type debugNotifier struct {
 	reportConfig report.ReportConfig
+	templates    *Templates
+
+	uploadThreshold   int
+	uploadTopFailures int
+}
+
+func NewDebugNotifier(reportConfig report.ReportConfig) *debugNotifier {
+	return &debugNotifier{
+		reportConfig: reportConfig,
+		templates:    DefaultTemplates(),
+	}
+}
+
+// WithTemplates sets the templates the messages are rendered with
+func (c *debugNotifier) WithTemplates(templates *Templates) *debugNotifier {
+	c.templates = templates
+	return c
+}
+
+// WithFileUpload matches the live notifier's `WithFileUpload`, so that the same failures are
+// shown
+func (c *debugNotifier) WithFileUpload(threshold int, topFailures int) *debugNotifier {
+	c.uploadThreshold = threshold
+	c.uploadTopFailures = topFailures
+	return c
 }
 
-func NewDebugNotifier() *debugNotifier {
-	return &debugNotifier{}
+func (c *debugNotifier) Render(report report.ReportJson) ([]RenderedMessage, error) {
+	return NewRenderer(c.reportConfig).
+		WithTemplates(c.templates).
+		WithFileUpload(c.uploadThreshold, c.uploadTopFailures).
+		Render(report)
 }
 
-func (c *debugNotifier) SendSummaryReport(report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error) {
-	blocks := buildSummaryReportBlocks(c.reportConfig, report)
-	bytes, err := json.MarshalIndent(blocks, "", "  ")
+func (c *debugNotifier) SendSummaryReport(ctx context.Context, report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error) {
+	msgs, err := buildSummaryReportMessages(c.templates, c.reportConfig, report)
+	if err != nil {
+		return NewResponseTimestamp(""), err
+	}
+	bytes, err := json.MarshalIndent(msgs, "", "  ")
 	if err != nil {
 		return NewResponseTimestamp(""), err
 	}
@@ -158,13 +395,16 @@ func (c *debugNotifier) SendSummaryReport(report report.ReportJson, updateMessag
 	return NewResponseTimestamp("placeholder"), nil
 }
 
-func (c *debugNotifier) SendEnvironmentReport(parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) error {
-	attachments := buildEnvironmentReport(env)
-	bytes, err := json.MarshalIndent(attachments, "", "  ")
+func (c *debugNotifier) SendEnvironmentReport(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) (envReportTs ResponseTimestamp, err error) {
+	msgs, err := buildEnvironmentReportMessages(c.templates, c.reportConfig, env, shownFailures(env, c.uploadThreshold, c.uploadTopFailures))
 	if err != nil {
-		return err
+		return NewResponseTimestamp(""), err
+	}
+	bytes, err := json.MarshalIndent(msgs, "", "  ")
+	if err != nil {
+		return NewResponseTimestamp(""), err
 	}
 	log.Debug(string(bytes) + "\n")
 
-	return nil
+	return NewResponseTimestamp("placeholder"), nil
 }
@@ -0,0 +1,103 @@
+package slacknotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dsab.slacker/report"
+)
+
+// maxSummaryErrorLength limits the error message shown in the summary for errored
+// environments. The full message is shown in the environment reply
+const maxSummaryErrorLength = 150
+
+// statusIcons lead the summary line & reply of environments that haven't completed
+var statusIcons = map[report.Status]string{
+	report.Pending: ":hourglass:",
+	report.Running: ":arrows_counterclockwise:",
+	report.Errored: ":x:",
+	report.Skipped: ":fast_forward:",
+}
+
+// statusColours colour the replies of environments that haven't completed
+var statusColours = map[report.Status]string{
+	report.Pending: "#CCCCCC",
+	report.Running: "#439FE0",
+	report.Errored: "#8B0000",
+	report.Skipped: "#808080",
+}
+
+// statusIcon leads the summary line & reply of each environment: its health icon when
+// completed, otherwise its status icon
+func statusIcon(env report.ReportEnvironment) string {
+	if env.Status == report.Completed {
+		return healthIcons[env.Health()]
+	}
+	if icon, ok := statusIcons[env.Status]; ok {
+		return icon
+	}
+	return ":grey_question:"
+}
+
+// describeEnvironment describes the health of a completed environment, or the status of
+// any other. Error messages are shortened to their first line when `short`
+func describeEnvironment(env report.ReportEnvironment, short bool) string {
+	if env.Status == report.Completed {
+		return buildHealthDescription(env)
+	}
+	return buildStatusDescription(env, short)
+}
+
+// buildStatusDescription describes an environment that hasn't completed, including why it
+// errored or was skipped. Error messages are shortened to their first line when `short`
+func buildStatusDescription(env report.ReportEnvironment, short bool) string {
+	var description string
+
+	switch env.Status {
+	case report.Pending:
+		description = "Pending"
+	case report.Running:
+		description = "Running"
+		if env.StartedAt != nil {
+			description += " since " + formatTimestamp(*env.StartedAt)
+		}
+	case report.Errored:
+		description = "Errored"
+	case report.Skipped:
+		description = "Skipped"
+	default:
+		return fmt.Sprintf("Unknown status '%s'", env.Status)
+	}
+
+	if env.Error != "" {
+		message := env.Error
+		if short {
+			message = shortenError(message)
+		}
+		description += " - " + message
+	}
+
+	return description
+}
+
+// shortenError shortens an error message to its first line, limited to
+// `maxSummaryErrorLength` characters
+func shortenError(message string) string {
+	firstLine, _, multiline := strings.Cut(message, "\n")
+	runes := []rune(firstLine)
+
+	if len(runes) > maxSummaryErrorLength {
+		return string(runes[:maxSummaryErrorLength-1]) + "…"
+	}
+	if multiline {
+		return firstLine + " …"
+	}
+	return firstLine
+}
+
+// formatTimestamp formats a timestamp in the reader's timezone, falling back to UTC for
+// clients that can't
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), t.UTC().Format("02 Jan 2006 15:04 UTC"))
+}
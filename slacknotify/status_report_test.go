@@ -0,0 +1,77 @@
+package slacknotify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+func TestBuildSummaryHealthMessageByStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	startedAt := time.Date(2023, 9, 20, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(":hourglass: *dev1* | Pending",
+		renderSummaryLine(t, report.ReportEnvironment{Name: "dev1", Status: report.Pending}, nil, nil))
+	assert.Equal(":arrows_counterclockwise: *dev1* | Running since <!date^1695200400^{date_short_pretty} {time}|20 Sep 2023 09:00 UTC>",
+		renderSummaryLine(t, report.ReportEnvironment{Name: "dev1", Status: report.Running, StartedAt: &startedAt}, nil, nil))
+	assert.Equal(":x: *dev1* | Errored - cluster unreachable … · <https://jenkins/1/console|View logs>",
+		renderSummaryLine(t, report.ReportEnvironment{
+			Name: "dev1", Status: report.Errored, Error: "cluster unreachable\ndial tcp: i/o timeout", LogUrl: "https://jenkins/1/console",
+		}, nil, nil))
+	assert.Equal(":fast_forward: *dev1* | Skipped - maintenance window",
+		renderSummaryLine(t, report.ReportEnvironment{Name: "dev1", Status: report.Skipped, Error: "maintenance window"}, nil, nil))
+	assert.Equal(":grey_question: *dev1* | Unknown status 'done'",
+		renderSummaryLine(t, report.ReportEnvironment{Name: "dev1", Status: "done"}, nil, nil))
+}
+
+func TestShortenError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("boom", shortenError("boom"))
+	assert.Equal("boom …", shortenError("boom\nstack trace"))
+
+	shortened := shortenError(strings.Repeat("a", 200))
+	assert.Equal(maxSummaryErrorLength, len([]rune(shortened)))
+	assert.True(strings.HasSuffix(shortened, "…"))
+}
+
+func TestAttachmentColourByStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("#CCCCCC", attachmentColour(report.ReportEnvironment{Status: report.Pending}))
+	assert.Equal("#439FE0", attachmentColour(report.ReportEnvironment{Status: report.Running}))
+	assert.Equal("#8B0000", attachmentColour(report.ReportEnvironment{Status: report.Errored}))
+	assert.Equal("#808080", attachmentColour(report.ReportEnvironment{Status: report.Skipped}))
+	assert.Equal("#00FF00", attachmentColour(report.ReportEnvironment{Status: report.Completed}))
+	assert.Equal("#FF0000", attachmentColour(report.ReportEnvironment{Status: "done"}))
+}
+
+func TestBuildEnvironmentReportHeaderForErroredEnvironment(t *testing.T) {
+	assert := assert.New(t)
+
+	startedAt := time.Date(2023, 9, 20, 9, 0, 0, 0, time.UTC)
+	finishedAt := startedAt.Add(12*time.Minute + 30*time.Second)
+
+	header := renderEnvironmentReport(t, report.ReportEnvironment{
+		Name:       "dev1",
+		Status:     report.Errored,
+		Error:      "cluster unreachable\ndial tcp: i/o timeout",
+		LogUrl:     "https://jenkins/1/console",
+		StartedAt:  &startedAt,
+		FinishedAt: &finishedAt,
+	}, nil, 0)[0]
+
+	assert.Equal(":x: Errored - cluster unreachable\ndial tcp: i/o timeout", header.Text)
+	assert.Equal("#8B0000", header.Color)
+	assert.Equal("https://jenkins/1/console", header.TitleLink)
+	if assert.Len(header.Fields, 3) {
+		assert.Equal("Started", header.Fields[0].Title)
+		assert.Equal("Finished", header.Fields[1].Title)
+		assert.Equal("12m30s", header.Fields[2].Value)
+	}
+}
@@ -1,6 +1,7 @@
 package slacknotify
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/slack-go/slack"
@@ -8,33 +9,46 @@ import (
 	"dsab.slacker/report"
 )
 
-// buildSummaryHealthMessage builds the message that is used in the top-level summary message,
-// describing each environment and its health & errors
-func buildSummaryHealthMessage(env report.ReportEnvironment) *slack.TextBlockObject {
-	var msg string
+// healthIcons lead the health message of each environment
+var healthIcons = map[report.Health]string{
+	report.Healthy:   ":white_check_mark:",
+	report.Degraded:  ":warning:",
+	report.Unhealthy: ":rotating_light:",
+}
 
-	switch env.Status {
-	case report.Pending:
-		msg = fmt.Sprintf(":hourglass: *%s* | Pending", env.Name)
-	case report.Completed:
-		if env.IsHealthy() {
-			msg = fmt.Sprintf(":white_check_mark: *%s* | Healthy", env.Name)
-		} else {
-			msg = fmt.Sprintf(":rotating_light: *%s* | Unhealthy - %d issues", env.Name, env.Errors())
+// buildHealthDescription describes the health of a completed environment, counting its
+// errors & warnings separately
+func buildHealthDescription(env report.ReportEnvironment) string {
+	switch env.Health() {
+	case report.Unhealthy:
+		if warnings := env.Warnings(); warnings > 0 {
+			return fmt.Sprintf("Unhealthy - %d issues, %d warnings", env.Errors(), warnings)
 		}
+		return fmt.Sprintf("Unhealthy - %d issues", env.Errors())
+	case report.Degraded:
+		return fmt.Sprintf("Degraded - %d warnings", env.Warnings())
 	default:
-		msg = fmt.Sprintf(":x: *%s* | Unknown failure", env.Name)
+		return "Healthy"
 	}
-
-	return markdown(msg)
 }
 
 // attachmentColour builds a colour Hex code used to colour Slack attachment messages based
-// on the health of the environment
+// on the health of the environment: green when healthy, amber when degraded & red when
+// unhealthy. Environments that haven't completed are coloured by their status instead
 func attachmentColour(env report.ReportEnvironment) string {
-	if env.IsHealthy() {
+	if env.Status != report.Completed {
+		if colour, ok := statusColours[env.Status]; ok {
+			return colour
+		}
+		return "#FF0000"
+	}
+
+	switch env.Health() {
+	case report.Healthy:
 		return "#00FF00"
-	} else {
+	case report.Degraded:
+		return "#FFBF00"
+	default:
 		return "#FF0000"
 	}
 }
@@ -44,49 +58,52 @@ func buildReportUrl(reportConfig report.ReportConfig, env report.ReportEnvironme
 	return fmt.Sprintf("%s/%s/%s", reportConfig.BaseUrl, reportConfig.ReportDate, env.Name)
 }
 
-func buildSummaryReportBlocks(reportConfig report.ReportConfig, reportJson report.ReportJson) []slack.Block {
-	blocks := []slack.Block{
-		slack.NewHeaderBlock(plaintext(":stethoscope: Bring-up Healthchecks")),
-		slack.NewSectionBlock(
-			nil,
-			[]*slack.TextBlockObject{
-				markdown(":date: *Date:* 20/09/2023"),
-				markdown(":rocket: *Jenkins job:* <https://google.com|1289>"),
-			},
-			nil,
-		),
-		slack.NewContextBlock("", markdown("Non-prod environments")),
-		slack.NewDividerBlock(),
+// summaryTemplateData is the data `SummaryTemplate` is rendered with
+type summaryTemplateData struct {
+	Config   report.ReportConfig
+	Metadata report.ReportMetadata
+	// Date is the `ReportConfig` date, which the report is linked to & found under, rather
+	// than the date in the report's metadata, so that the two can't disagree
+	Date         string
+	Environments []summaryEnvironmentData
+}
+
+// summaryEnvironmentData is each environment shown in the summary, along with how it's
+// changed since the previous report & its recent trend, when available
+type summaryEnvironmentData struct {
+	Environment report.ReportEnvironment
+	Diff        *report.EnvironmentDiff
+	Trend       *report.EnvironmentTrend
+	ReportUrl   string
+}
+
+// buildSummaryReportBlocks renders the blocks of the summary report
+func buildSummaryReportBlocks(templates *Templates, reportConfig report.ReportConfig, reportJson report.ReportJson) ([]slack.Block, error) {
+	data := &summaryTemplateData{
+		Config:       reportConfig,
+		Metadata:     reportJson.Metadata,
+		Date:         reportConfig.ReportDate,
+		Environments: []summaryEnvironmentData{},
 	}
 
 	for _, env := range reportJson.Environments {
-		blocks = append(blocks, buildEnvironmentSummarySection(reportConfig, env))
+		data.Environments = append(data.Environments, summaryEnvironmentData{
+			Environment: env,
+			Diff:        report.DiffEnvironment(reportConfig.PreviousReport, env),
+			Trend:       report.TrendEnvironment(reportConfig.ReportDate, reportConfig.PastReports, env, reportConfig.TrendDays),
+			ReportUrl:   buildReportUrl(reportConfig, env),
+		})
 	}
 
-	blocks = append(blocks,
-		slack.NewActionBlock("",
-			linkButton(":arrow_left: Yesterday's report", "https://google.com"),
-			linkButton(":information_source: Learn more", "https://google.com"),
-		),
-	)
-
-	return blocks
-}
-
-func buildEnvironmentSummarySection(reportConfig report.ReportConfig, env report.ReportEnvironment) *slack.SectionBlock {
-	var button *slack.Accessory
+	rendered, err := templates.execute(SummaryTemplate, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not render summary report: %v", err)
+	}
 
-	button = slack.NewAccessory(
-		&slack.ButtonBlockElement{
-			Type: slack.METButton,
-			Text: plaintext(":clipboard: See report"),
-			URL:  buildReportUrl(reportConfig, env),
-		},
-	)
+	var blocks slack.Blocks
+	if err := json.Unmarshal(rendered, &blocks); err != nil {
+		return nil, fmt.Errorf("summary report template did not produce a JSON array of blocks: %v", err)
+	}
 
-	return slack.NewSectionBlock(
-		buildSummaryHealthMessage(env),
-		nil,
-		button,
-	)
+	return blocks.BlockSet, nil
 }
@@ -0,0 +1,148 @@
+package slacknotify
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"dsab.slacker/report"
+)
+
+// The templates each message is rendered with, producing Block Kit JSON
+const (
+	SummaryTemplate     = "summary.json.tmpl"
+	EnvironmentTemplate = "environment.json.tmpl"
+)
+
+// templateFiles are the default templates, laying out the messages as they've always been
+//
+//go:embed templates/*.json.tmpl
+var templateFiles embed.FS
+
+var defaultTemplates = &Templates{
+	template: template.Must(template.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.json.tmpl")),
+}
+
+// Templates render the summary & environment messages from Go `text/template`s producing
+// Block Kit JSON
+type Templates struct {
+	template *template.Template
+}
+
+// DefaultTemplates returns the templates shipped with slacker, in `templates/`
+func DefaultTemplates() *Templates {
+	return defaultTemplates
+}
+
+// LoadTemplates loads the `*.tmpl` files in `dir` on top of the default templates. Files
+// named after `SummaryTemplate` or `EnvironmentTemplate` replace that message's layout, and
+// any `define`d template replaces the default template of the same name, eg.
+// `environmentLine`
+func LoadTemplates(dir string) (*Templates, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("could not read template directory: %v", err)
+		}
+		return nil, fmt.Errorf("no templates found in '%s'", dir)
+	}
+
+	tmpl, err := defaultTemplates.template.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tmpl.ParseFiles(files...); err != nil {
+		return nil, fmt.Errorf("could not parse templates: %v", err)
+	}
+
+	return &Templates{template: tmpl}, nil
+}
+
+// execute renders the template `name` with `data`
+func (t *Templates) execute(name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	// `include` renders another template of this set, so is bound to it on each execution
+	tmpl, err := t.template.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			var buf strings.Builder
+			err := tmpl.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+	})
+
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+	// Replaced on execution, see `Templates.execute`
+	"include": func(name string, data any) (string, error) {
+		return "", fmt.Errorf("include is not available")
+	},
+
+	"json":        toJson,
+	"mrkdwn":      func(text string) (string, error) { return toJson(markdown(text)) },
+	"plaintext":   func(text string) (string, error) { return toJson(plaintext(text)) },
+	"button":      func(text string, url string) (string, error) { return toJson(linkButton(text, url)) },
+	"emoji":       emoji,
+	"link":        link,
+	"chunk":       func(lines []string) []string { return chunkLines(lines, maxSectionTextLength) },
+	"join":        func(values []string, sep string) string { return strings.Join(values, sep) },
+	"shortCommit": shortCommit,
+	"timestamp":   formatTimestamp,
+	"duration":    func(start time.Time, end time.Time) string { return end.Sub(start).Round(time.Second).String() },
+	"isDayBefore": isDayBefore,
+
+	"statusIcon":    statusIcon,
+	"statusSummary": func(env report.ReportEnvironment) string { return describeEnvironment(env, true) },
+	"statusDetail":  func(env report.ReportEnvironment) string { return describeEnvironment(env, false) },
+	"colour":        attachmentColour,
+	"diffSummary":   buildDiffSummary,
+	"trendSummary":  buildTrendSummary,
+	"sparkline":     buildTrendSparkline,
+}
+
+// toJson renders `v` as JSON, eg. to quote a string
+func toJson(v any) (string, error) {
+	bytes, err := json.Marshal(v)
+	return string(bytes), err
+}
+
+// emoji wraps an emoji name in colons, eg. `emoji "rocket"` is `:rocket:`
+func emoji(name string) string {
+	return ":" + strings.Trim(name, ":") + ":"
+}
+
+// link builds a Slack link to `url` showing `text`, or just `text` without a URL
+func link(url string, text string) string {
+	if url == "" {
+		return text
+	}
+	return fmt.Sprintf("<%s|%s>", url, text)
+}
+
+// shortCommit shortens a git commit hash to 7 characters
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
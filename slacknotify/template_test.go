@@ -0,0 +1,130 @@
+package slacknotify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/report"
+)
+
+// renderTemplate renders the default template `name` with `data`
+func renderTemplate(t *testing.T, name string, data any) string {
+	rendered, err := DefaultTemplates().execute(name, data)
+	require.NoError(t, err)
+	return string(rendered)
+}
+
+// renderSummaryLine renders an environment's line in the summary
+func renderSummaryLine(t *testing.T, env report.ReportEnvironment, diff *report.EnvironmentDiff, trend *report.EnvironmentTrend) string {
+	return renderTemplate(t, "environmentLine", &summaryEnvironmentData{Environment: env, Diff: diff, Trend: trend})
+}
+
+// renderEnvironmentReport renders the attachments of an environment reply
+func renderEnvironmentReport(t *testing.T, env report.ReportEnvironment, diff *report.EnvironmentDiff, maxFailures int) []slack.Attachment {
+	attachments, err := buildEnvironmentReport(DefaultTemplates(), env, diff, maxFailures, nil)
+	require.NoError(t, err)
+	return attachments
+}
+
+func writeTemplate(t *testing.T, dir string, name string, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestDefaultSummaryTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks, err := buildSummaryReportBlocks(DefaultTemplates(), report.ReportConfig{
+		ReportDate:         "21-09-2023",
+		BaseUrl:            "https://reports",
+		PreviousReportDate: "20-09-2023",
+		PreviousReportUrl:  "https://slack/previous",
+		LearnMoreUrl:       "https://docs",
+	}, report.ReportJson{
+		Metadata: report.ReportMetadata{Date: "20-09-2023", JobName: "Bring-up", BuildNumber: "12", BuildUrl: "https://ci/12", GitCommit: "0123456789abcdef"},
+		Environments: []report.ReportEnvironment{
+			{Name: "dev1", Status: report.Completed},
+			{Name: "dev2", Status: report.Pending},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, blocks, 7)
+
+	assert.Equal(":stethoscope: Bring-up Healthchecks", blocks[0].(*slack.HeaderBlock).Text.Text)
+
+	// The report date the report is linked to & found under is shown, rather than its own
+	fields := blocks[1].(*slack.SectionBlock).Fields
+	assert.Equal(":date: *Date:* 21-09-2023", fields[0].Text)
+	assert.Equal(":rocket: *Bring-up:* <https://ci/12|12>", fields[1].Text)
+
+	context := blocks[2].(*slack.ContextBlock).ContextElements.Elements
+	assert.Equal(":memo: Commit `0123456`", context[1].(*slack.TextBlockObject).Text)
+
+	env := blocks[4].(*slack.SectionBlock)
+	assert.Equal(":white_check_mark: *dev1* | Healthy", env.Text.Text)
+	assert.Equal("https://reports/21-09-2023/dev1", env.Accessory.ButtonElement.URL)
+
+	actions := blocks[6].(*slack.ActionBlock).Elements.ElementSet
+	assert.Equal(":arrow_left: Yesterday's report", actions[0].(*slack.ButtonBlockElement).Text.Text)
+	assert.Equal("https://docs", actions[1].(*slack.ButtonBlockElement).URL)
+}
+
+func TestDefaultTemplatesEscapeText(t *testing.T) {
+	blocks, err := buildSummaryReportBlocks(DefaultTemplates(), report.ReportConfig{}, report.ReportJson{
+		Metadata: report.ReportMetadata{Title: `"Quoted" \ title`},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `"Quoted" \ title`, blocks[0].(*slack.HeaderBlock).Text.Text)
+}
+
+func TestLoadTemplatesOverridesDefaults(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	writeTemplate(t, dir, "custom.tmpl", `{{ define "environmentLine" }}{{ emoji "eyes" }} {{ .Environment.Name }}: {{ statusSummary .Environment }}{{ end }}`)
+	writeTemplate(t, dir, EnvironmentTemplate, `[{"color": {{ colour .Environment | json }}, "text": {{ link "https://logs" .Environment.Name | json }}}]`)
+
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed}
+
+	blocks, err := buildSummaryReportBlocks(templates, report.ReportConfig{}, report.ReportJson{Environments: []report.ReportEnvironment{env}})
+	require.NoError(t, err)
+	assert.Equal(":eyes: dev1: Healthy", blocks[4].(*slack.SectionBlock).Text.Text)
+
+	attachments, err := buildEnvironmentReport(templates, env, nil, 0, nil)
+	require.NoError(t, err)
+	assert.Equal([]slack.Attachment{{Color: "#00FF00", Text: "<https://logs|dev1>"}}, attachments)
+
+	// The defaults are left untouched
+	assert.Equal(":white_check_mark: *dev1* | Healthy", renderSummaryLine(t, env, nil, nil))
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := LoadTemplates(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(err, "could not read template directory")
+
+	_, err = LoadTemplates(t.TempDir())
+	assert.ErrorContains(err, "no templates found")
+
+	dir := t.TempDir()
+	writeTemplate(t, dir, SummaryTemplate, `{{ if }}`)
+	_, err = LoadTemplates(dir)
+	assert.ErrorContains(err, "could not parse templates")
+
+	dir = t.TempDir()
+	writeTemplate(t, dir, SummaryTemplate, `{"blocks": []}`)
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+
+	_, err = buildSummaryReportBlocks(templates, report.ReportConfig{}, report.ReportJson{})
+	assert.ErrorContains(err, "did not produce a JSON array of blocks")
+}
@@ -0,0 +1,245 @@
+package slacknotify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// TokenSource provides the Slack API token, so that it needn't be passed on the command line
+// where it would be visible in process listings & CI logs
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// Interface assertions
+var (
+	_ TokenSource = (*staticTokenSource)(nil)
+	_ TokenSource = (*fileTokenSource)(nil)
+	_ TokenSource = (*readerTokenSource)(nil)
+)
+
+// HTTPClient makes the HTTP requests of Slack API calls, as accepted by
+// `slack.OptionHTTPClient`
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// defaultHTTPClient makes the Slack API calls, unless another client is given. Each request
+// is bounded, so that a hung connection is retried rather than stalling the whole run
+var defaultHTTPClient = &http.Client{Timeout: time.Minute}
+
+// newClient creates a Slack API client using the token from `tokenSource`. The HTTP client
+// defaults to `defaultHTTPClient`, and can be replaced with `slack.OptionHTTPClient`
+func newClient(tokenSource TokenSource, options ...slack.Option) (*slack.Client, error) {
+	token, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("could not read token: %v", err)
+	}
+
+	return slack.New(token, append([]slack.Option{slack.OptionHTTPClient(defaultHTTPClient)}, options...)...), nil
+}
+
+// checkToken trims the whitespace around a token read from `source`, eg. a trailing newline,
+// ensuring that something is left
+func checkToken(token string, source string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("no token found in %s", source)
+	}
+	return token, nil
+}
+
+//-----------------------------------------------------------------------------------------
+
+type staticTokenSource struct {
+	token string
+}
+
+// NewStaticTokenSource provides a token given directly, eg. by a flag or env var
+func NewStaticTokenSource(token string) *staticTokenSource {
+	return &staticTokenSource{token: token}
+}
+
+func (s *staticTokenSource) Token() (string, error) {
+	if s.token == "" {
+		return "", fmt.Errorf("no token given")
+	}
+	return s.token, nil
+}
+
+//-----------------------------------------------------------------------------------------
+
+type fileTokenSource struct {
+	path string
+}
+
+// NewFileTokenSource reads the token from the file at `path`, eg. a mounted secret
+func NewFileTokenSource(path string) *fileTokenSource {
+	return &fileTokenSource{path: path}
+}
+
+// Token reads the file on each call. Clients only read the token when they're created at the
+// start of a run, so a token rotated during a run is only picked up by the next run
+func (s *fileTokenSource) Token() (string, error) {
+	bytes, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	return checkToken(string(bytes), fmt.Sprintf("token file '%s'", s.path))
+}
+
+//-----------------------------------------------------------------------------------------
+
+type readerTokenSource struct {
+	reader io.Reader
+	name   string
+
+	once  sync.Once
+	token string
+	err   error
+}
+
+// NewReaderTokenSource reads the token from `reader`, eg. stdin or an inherited file
+// descriptor, named `name` in errors
+func NewReaderTokenSource(reader io.Reader, name string) *readerTokenSource {
+	return &readerTokenSource{reader: reader, name: name}
+}
+
+// Token reads the token on the first call only, as the reader can't be read again
+func (s *readerTokenSource) Token() (string, error) {
+	s.once.Do(func() {
+		var bytes []byte
+		if bytes, s.err = io.ReadAll(s.reader); s.err == nil {
+			s.token, s.err = checkToken(string(bytes), s.name)
+		}
+	})
+	return s.token, s.err
+}
+
+//-----------------------------------------------------------------------------------------
+
+// scopesHeader is the response header listing the OAuth scopes granted to the token
+const scopesHeader = "X-OAuth-Scopes"
+
+// scopeRecorder records the scopes granted to the token, which Slack only returns in the
+// headers of each response
+type scopeRecorder struct {
+	client HTTPClient
+
+	mu     sync.Mutex
+	scopes string
+}
+
+func (r *scopeRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err == nil {
+		r.mu.Lock()
+		r.scopes = resp.Header.Get(scopesHeader)
+		r.mu.Unlock()
+	}
+	return resp, err
+}
+
+type tokenValidator struct {
+	tokenSource TokenSource
+	options     []slack.Option
+	httpClient  HTTPClient
+	retryConfig RetryConfig
+}
+
+// NewTokenValidator checks the token from `tokenSource` before anything is sent, so that an
+// invalid token or missing scope fails the run up front rather than part way through
+func NewTokenValidator(tokenSource TokenSource, options ...slack.Option) *tokenValidator {
+	return &tokenValidator{
+		tokenSource: tokenSource,
+		options:     options,
+		httpClient:  defaultHTTPClient,
+		retryConfig: DefaultRetryConfig(),
+	}
+}
+
+// WithHTTPClient makes the calls with `client`. The client is wrapped to read the scopes
+// granted to the token, so must be given here rather than with `slack.OptionHTTPClient`
+func (v *tokenValidator) WithHTTPClient(client HTTPClient) *tokenValidator {
+	v.httpClient = client
+	return v
+}
+
+// WithRetry sets how failed Slack API calls are retried
+func (v *tokenValidator) WithRetry(retryConfig RetryConfig) *tokenValidator {
+	v.retryConfig = retryConfig
+	return v
+}
+
+// Validate calls `auth.test` with the token, and ensures that it has been granted each of
+// `scopes`. Alternative scopes are separated by `|`, eg. `channels:history|groups:history`
+// for public or private channels. Tokens whose scopes Slack doesn't return, eg. legacy
+// tokens, are only checked with `auth.test`
+func (v *tokenValidator) Validate(ctx context.Context, scopes ...string) (*slack.AuthTestResponse, error) {
+	recorder := &scopeRecorder{client: v.httpClient}
+	options := append([]slack.Option{}, v.options...)
+	client, err := newClient(v.tokenSource, append(options, slack.OptionHTTPClient(recorder))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth *slack.AuthTestResponse
+	err = v.retryConfig.retry(ctx, "auth.test", func() (err error) {
+		auth, err = client.AuthTestContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token was rejected by Slack: %v", err)
+	}
+
+	if recorder.scopes == "" {
+		return auth, nil
+	}
+
+	granted := map[string]bool{}
+	for _, scope := range strings.Split(recorder.scopes, ",") {
+		granted[strings.TrimSpace(scope)] = true
+	}
+
+	var missing []string
+	for _, required := range scopes {
+		if !anyGranted(granted, strings.Split(required, "|")) {
+			missing = append(missing, "'"+strings.ReplaceAll(required, "|", "' or '")+"'")
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("token is missing the scopes %s (granted: %s) - add them to the Slack app & reinstall it",
+			strings.Join(missing, ", "), joinScopes(granted))
+	}
+
+	return auth, nil
+}
+
+// anyGranted returns whether any of the alternative `scopes` has been `granted`
+func anyGranted(granted map[string]bool, scopes []string) bool {
+	for _, scope := range scopes {
+		if granted[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// joinScopes lists the granted scopes for error messages
+func joinScopes(granted map[string]bool) string {
+	scopes := make([]string, 0, len(granted))
+	for scope := range granted {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return strings.Join(scopes, ", ")
+}
@@ -0,0 +1,78 @@
+package slacknotify
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"dsab.slacker/internal/fakeslack"
+)
+
+func TestFileTokenSource(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "token")
+
+	_, err := NewFileTokenSource(path).Token()
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, []byte("  \n"), 0o600))
+	_, err = NewFileTokenSource(path).Token()
+	assert.ErrorContains(err, "no token found in token file")
+
+	require.NoError(t, os.WriteFile(path, []byte("xoxb-secret\n"), 0o600))
+	token, err := NewFileTokenSource(path).Token()
+	require.NoError(t, err)
+	assert.Equal("xoxb-secret", token)
+}
+
+func TestReaderTokenSourceReadsOnce(t *testing.T) {
+	assert := assert.New(t)
+	source := NewReaderTokenSource(strings.NewReader("xoxb-secret\n"), "stdin")
+
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		require.NoError(t, err)
+		assert.Equal("xoxb-secret", token)
+	}
+
+	_, err := NewReaderTokenSource(strings.NewReader(""), "stdin").Token()
+	assert.EqualError(err, "no token found in stdin")
+
+	_, err = NewStaticTokenSource("").Token()
+	assert.EqualError(err, "no token given")
+}
+
+// countingClient counts the requests made through it
+type countingClient struct {
+	requests int
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultClient.Do(req)
+}
+
+func TestTokenValidatorWrapsHTTPClient(t *testing.T) {
+	assert := assert.New(t)
+	fake := fakeslack.New(t)
+	fake.SetScopes("chat:write")
+
+	client := &countingClient{}
+	validator := NewTokenValidator(NewStaticTokenSource("xoxb-test"), slack.OptionAPIURL(fake.APIURL())).
+		WithHTTPClient(client)
+
+	_, err := validator.Validate(context.Background(), "chat:write")
+	require.NoError(t, err)
+	assert.Equal(1, client.requests)
+
+	_, err = validator.Validate(context.Background(), "files:write")
+	assert.ErrorContains(err, "token is missing the scopes 'files:write' (granted: chat:write)")
+	assert.Equal(2, client.requests)
+}
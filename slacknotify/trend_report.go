@@ -0,0 +1,80 @@
+package slacknotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dsab.slacker/report"
+)
+
+// buildTrendSummary describes how an environment's errors have changed since its previous
+// report & how long it's had the same health, eg. ` (▲2 vs yesterday, 3 days in a row)`,
+// to be appended to a health message. It is empty when there's no trend to show
+func buildTrendSummary(trend *report.EnvironmentTrend) string {
+	if trend == nil {
+		return ""
+	}
+
+	parts := []string{}
+
+	if trend.PreviousDate != "" {
+		today := trend.Days[len(trend.Days)-1]
+
+		since := "vs " + trend.PreviousDate
+		if isDayBefore(today.Date, trend.PreviousDate) {
+			since = "vs yesterday"
+		}
+
+		switch change := today.Errors - trend.PreviousErrors; {
+		case change > 0:
+			parts = append(parts, fmt.Sprintf("▲%d %s", change, since))
+		case change < 0:
+			parts = append(parts, fmt.Sprintf("▼%d %s", -change, since))
+		default:
+			parts = append(parts, "no change "+since)
+		}
+	}
+
+	if trend.Streak > 1 {
+		parts = append(parts, fmt.Sprintf("%d days in a row", trend.Streak))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// sparklineSquares show an environment's health on each reported day of its trend
+var sparklineSquares = map[report.Health]string{
+	report.Healthy:   ":large_green_square:",
+	report.Degraded:  ":large_orange_square:",
+	report.Unhealthy: ":large_red_square:",
+}
+
+// buildTrendSparkline builds a strip of squares showing an environment's health on each day
+// of the trend, oldest first: green when healthy, orange when degraded, red when unhealthy &
+// white when it wasn't reported. It is empty when there's no trend to show
+func buildTrendSparkline(trend *report.EnvironmentTrend) string {
+	if trend == nil {
+		return ""
+	}
+
+	var sparkline strings.Builder
+	for _, day := range trend.Days {
+		if !day.Reported {
+			sparkline.WriteString(":white_large_square:")
+			continue
+		}
+		sparkline.WriteString(sparklineSquares[day.Health])
+	}
+
+	return sparkline.String()
+}
+
+// isDayBefore checks whether `previousDate` is the day before `date`
+func isDayBefore(date string, previousDate string) bool {
+	reportDate, err := time.Parse(report.DateFormat, date)
+	return err == nil && reportDate.AddDate(0, 0, -1).Format(report.DateFormat) == previousDate
+}
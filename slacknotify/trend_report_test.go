@@ -0,0 +1,68 @@
+package slacknotify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"dsab.slacker/report"
+)
+
+func TestBuildTrendSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	days := []report.TrendDay{
+		{Date: "19-09-2023"},
+		{Date: "20-09-2023", Errors: 3, Reported: true},
+		{Date: "21-09-2023", Errors: 5, Reported: true},
+	}
+
+	assert.Equal(" (▲2 vs yesterday, 2 days in a row)", buildTrendSummary(&report.EnvironmentTrend{
+		PreviousDate: "20-09-2023", PreviousErrors: 3, Streak: 2, Days: days,
+	}))
+	assert.Equal(" (▼4 vs 18-09-2023)", buildTrendSummary(&report.EnvironmentTrend{
+		PreviousDate: "18-09-2023", PreviousErrors: 9, Streak: 1, Days: days,
+	}))
+	assert.Equal(" (no change vs yesterday)", buildTrendSummary(&report.EnvironmentTrend{
+		PreviousDate: "20-09-2023", PreviousErrors: 5, Streak: 1, Days: days,
+	}))
+	assert.Equal("", buildTrendSummary(&report.EnvironmentTrend{Streak: 1, Days: days}))
+	assert.Equal("", buildTrendSummary(nil))
+}
+
+func TestBuildSummaryHealthMessageWithTrend(t *testing.T) {
+	env := report.ReportEnvironment{Name: "dev1", Status: report.Completed, Namespaces: []report.Namespace{
+		{Name: "ns1", Sections: []report.Section{{Name: "Failed Pods", Failures: report.NewFailures("foo", "bar")}}},
+	}}
+
+	trend := &report.EnvironmentTrend{
+		PreviousDate:   "20-09-2023",
+		PreviousErrors: 0,
+		Streak:         1,
+		Days: []report.TrendDay{
+			{Date: "19-09-2023"},
+			{Date: "20-09-2023", Errors: 0, Health: report.Healthy, Reported: true},
+			{Date: "21-09-2023", Errors: 2, Health: report.Unhealthy, Reported: true},
+		},
+	}
+
+	assert.Equal(t,
+		":rotating_light: *dev1* | Unhealthy - 2 issues (▲2 vs yesterday)\n"+
+			":white_large_square::large_green_square::large_red_square:",
+		renderSummaryLine(t, env, nil, trend),
+	)
+}
+
+func TestBuildTrendSparkline(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(":white_large_square::large_green_square::large_orange_square::large_red_square:", buildTrendSparkline(&report.EnvironmentTrend{
+		Days: []report.TrendDay{
+			{Date: "18-09-2023"},
+			{Date: "19-09-2023", Health: report.Healthy, Reported: true},
+			{Date: "20-09-2023", Health: report.Degraded, Reported: true},
+			{Date: "21-09-2023", Errors: 1, Health: report.Unhealthy, Reported: true},
+		},
+	}))
+	assert.Equal("", buildTrendSparkline(nil))
+}
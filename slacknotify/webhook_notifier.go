@@ -0,0 +1,115 @@
+package slacknotify
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+
+	"dsab.slacker/report"
+)
+
+//-----------------------------------------------------------------------------------------
+// Webhook
+
+// webhookNotifier sends reports via. an incoming webhook. Webhooks can't reply in threads,
+// update messages or carry metadata, so every message is sent as a new top-level post
+type webhookNotifier struct {
+	webhookUrl   string
+	reportConfig report.ReportConfig
+	templates    *Templates
+	username     string
+	retryConfig  RetryConfig
+}
+
+func NewWebhookNotifier(webhookUrl string, reportConfig report.ReportConfig) *webhookNotifier {
+	return &webhookNotifier{
+		webhookUrl:   webhookUrl,
+		reportConfig: reportConfig,
+		templates:    DefaultTemplates(),
+		retryConfig:  DefaultRetryConfig(),
+	}
+}
+
+// WithTemplates sets the templates the messages are rendered with
+func (c *webhookNotifier) WithTemplates(templates *Templates) *webhookNotifier {
+	c.templates = templates
+	return c
+}
+
+func (c *webhookNotifier) WithUsername(username string) *webhookNotifier {
+	c.username = username
+	return c
+}
+
+// WithRetry sets how failed webhook calls are retried
+func (c *webhookNotifier) WithRetry(retryConfig RetryConfig) *webhookNotifier {
+	c.retryConfig = retryConfig
+	return c
+}
+
+func (c *webhookNotifier) post(ctx context.Context, msg *slack.WebhookMessage) error {
+	msg.Username = c.username
+	return c.retryConfig.retryPost(ctx, "webhook", func() error {
+		return slack.PostWebhookContext(ctx, c.webhookUrl, msg)
+	})
+}
+
+// Render renders the messages posted, which never include uploads
+func (c *webhookNotifier) Render(report report.ReportJson) ([]RenderedMessage, error) {
+	return NewRenderer(c.reportConfig).WithTemplates(c.templates).Render(report)
+}
+
+// SendSummaryReport posts the summary report. As webhooks don't return the timestamp of the
+// posted message, the returned timestamp is always empty
+func (c *webhookNotifier) SendSummaryReport(ctx context.Context, report report.ReportJson, updateMessageTs *ResponseTimestamp) (summaryReportTs ResponseTimestamp, err error) {
+	if updateMessageTs != nil {
+		return NewResponseTimestamp(""), fmt.Errorf("webhooks cannot update existing messages")
+	}
+
+	msgs, err := buildSummaryReportMessages(c.templates, c.reportConfig, report)
+	if err != nil {
+		return NewResponseTimestamp(""), err
+	}
+
+	for i, msg := range msgs {
+		log.Debugf("Posting summary report part %d/%d", i+1, len(msgs))
+
+		err := c.post(ctx, &slack.WebhookMessage{
+			Blocks: &slack.Blocks{BlockSet: msg.Blocks},
+		})
+		if err != nil {
+			return NewResponseTimestamp(""), err
+		}
+	}
+
+	return NewResponseTimestamp(""), nil
+}
+
+// SendEnvironmentReport posts the environment report as top-level messages following the
+// summary report
+func (c *webhookNotifier) SendEnvironmentReport(ctx context.Context, parentMessageTs ResponseTimestamp, env report.ReportEnvironment, updateMessageTs *ResponseTimestamp) (envReportTs ResponseTimestamp, err error) {
+	if updateMessageTs != nil {
+		return NewResponseTimestamp(""), fmt.Errorf("webhooks cannot update existing messages")
+	}
+
+	msgs, err := buildEnvironmentReportMessages(c.templates, c.reportConfig, env, 0)
+	if err != nil {
+		return NewResponseTimestamp(""), err
+	}
+
+	for i, msg := range msgs {
+		log.WithField("env", env.Name).Debugf("Posting environment report part %d/%d", i+1, len(msgs))
+
+		err := c.post(ctx, &slack.WebhookMessage{
+			Text:        msg.Text,
+			Attachments: msg.Attachments,
+		})
+		if err != nil {
+			return NewResponseTimestamp(""), err
+		}
+	}
+
+	return NewResponseTimestamp(""), nil
+}